@@ -1,5 +1,17 @@
 package admin
 
+import "github.com/plakak13/assessment-tax/tax"
+
 type Setting struct {
-	Amount float64 `json:"amount" validate:"required" example:"100.00"`
+	Amount  tax.Money `json:"amount" validate:"required" example:"100.00"`
+	TaxYear int       `json:"taxYear" validate:"omitempty,gte=2500" example:"2568"`
+}
+
+type PercentSetting struct {
+	Percent tax.Rate `json:"percent" validate:"required" example:"10.00"`
+	TaxYear int      `json:"taxYear" validate:"omitempty,gte=2500" example:"2568"`
+}
+
+type TaxYearSetting struct {
+	Rates []tax.TaxRate `json:"rates" validate:"omitempty,min=2"`
 }
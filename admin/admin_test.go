@@ -22,6 +22,7 @@ type MockAdmin struct {
 	sqlResult         sql.Result
 	updateError       error
 	taxDeductionError error
+	seedError         error
 	errorExpected     error
 }
 
@@ -32,13 +33,24 @@ func (m MockAdmin) UpdateTaxDeduction(s postgres.SettingTaxDeduction) (sql.Resul
 	return m.sqlResult, nil
 }
 
-func (m MockAdmin) TaxDeductionByType([]string) ([]tax.TaxDeduction, error) {
+func (m MockAdmin) TaxDeductionByType(int, []string) ([]tax.TaxDeduction, error) {
 	if m.taxDeductionError != nil {
 		return nil, m.taxDeductionError
 	}
 	return m.taxDeductions, nil
 }
 
+func (m MockAdmin) UpdateTaxDeductionPercent(s postgres.SettingTaxDeduction) (sql.Result, error) {
+	if m.updateError != nil {
+		return nil, m.updateError
+	}
+	return m.sqlResult, nil
+}
+
+func (m MockAdmin) SeedTaxYear(int, []tax.TaxRate) error {
+	return m.seedError
+}
+
 func TestAdminHandler_Personal_Type_Success(t *testing.T) {
 	e := echo.New()
 	e.Validator = helper.NewValidator()
@@ -58,10 +70,10 @@ func TestAdminHandler_Personal_Type_Success(t *testing.T) {
 		taxDeductions: []tax.TaxDeduction{
 			{
 				ID:                 3,
-				MaxDeductionAmount: 100000,
-				DefaultAmount:      60000,
-				AdminOverrideMax:   100000,
-				MinAmount:          10000,
+				MaxDeductionAmount: 100000 * tax.Baht,
+				DefaultAmount:      60000 * tax.Baht,
+				AdminOverrideMax:   100000 * tax.Baht,
+				MinAmount:          10000 * tax.Baht,
 				TaxAllowanceType:   "personal",
 			},
 		},
@@ -71,6 +83,7 @@ func TestAdminHandler_Personal_Type_Success(t *testing.T) {
 	err := h.AdminHandler(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"personalDeduction":60000.00}`, rec.Body.String())
 }
 
 func TestAdminHandler_K_Receipt_Type_Success(t *testing.T) {
@@ -92,9 +105,9 @@ func TestAdminHandler_K_Receipt_Type_Success(t *testing.T) {
 		taxDeductions: []tax.TaxDeduction{
 			{
 				ID:                 2,
-				MaxDeductionAmount: 100000,
-				DefaultAmount:      50000,
-				AdminOverrideMax:   100000,
+				MaxDeductionAmount: 100000 * tax.Baht,
+				DefaultAmount:      50000 * tax.Baht,
+				AdminOverrideMax:   100000 * tax.Baht,
 				MinAmount:          0,
 				TaxAllowanceType:   "k-receipt",
 			},
@@ -105,6 +118,15 @@ func TestAdminHandler_K_Receipt_Type_Success(t *testing.T) {
 	err := h.AdminHandler(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"kReceipt":60000.00}`, rec.Body.String())
+}
+
+func TestResponseKey(t *testing.T) {
+	assert.Equal(t, "personalDeduction", responseKey("personal"))
+	assert.Equal(t, "kReceipt", responseKey("k-receipt"))
+	assert.Equal(t, "lifeInsurance", responseKey("life-insurance"))
+	assert.Equal(t, "child2561", responseKey("child-2561"))
+	assert.Equal(t, "donation", responseKey("donation"))
 }
 
 func TestAdminHandler_Failed(t *testing.T) {
@@ -198,10 +220,10 @@ func TestAdminHandler_Failed(t *testing.T) {
 			taxDeductions: []tax.TaxDeduction{
 				{
 					ID:                 3,
-					MaxDeductionAmount: 100000,
-					DefaultAmount:      60000,
-					AdminOverrideMax:   100000,
-					MinAmount:          10000,
+					MaxDeductionAmount: 100000 * tax.Baht,
+					DefaultAmount:      60000 * tax.Baht,
+					AdminOverrideMax:   100000 * tax.Baht,
+					MinAmount:          10000 * tax.Baht,
 					TaxAllowanceType:   "personal",
 				},
 			},
@@ -232,10 +254,10 @@ func TestAdminHandler_Failed(t *testing.T) {
 			taxDeductions: []tax.TaxDeduction{
 				{
 					ID:                 3,
-					MaxDeductionAmount: 100000,
-					DefaultAmount:      60000,
-					AdminOverrideMax:   100000,
-					MinAmount:          10000,
+					MaxDeductionAmount: 100000 * tax.Baht,
+					DefaultAmount:      60000 * tax.Baht,
+					AdminOverrideMax:   100000 * tax.Baht,
+					MinAmount:          10000 * tax.Baht,
 					TaxAllowanceType:   "personal",
 				},
 			},
@@ -266,10 +288,10 @@ func TestAdminHandler_Failed(t *testing.T) {
 			taxDeductions: []tax.TaxDeduction{
 				{
 					ID:                 3,
-					MaxDeductionAmount: 100000,
-					DefaultAmount:      60000,
-					AdminOverrideMax:   100000,
-					MinAmount:          10000,
+					MaxDeductionAmount: 100000 * tax.Baht,
+					DefaultAmount:      60000 * tax.Baht,
+					AdminOverrideMax:   100000 * tax.Baht,
+					MinAmount:          10000 * tax.Baht,
 					TaxAllowanceType:   "personal",
 				},
 			},
@@ -300,10 +322,10 @@ func TestAdminHandler_Failed(t *testing.T) {
 			taxDeductions: []tax.TaxDeduction{
 				{
 					ID:                 3,
-					MaxDeductionAmount: 100000,
-					DefaultAmount:      60000,
-					AdminOverrideMax:   100000,
-					MinAmount:          10000,
+					MaxDeductionAmount: 100000 * tax.Baht,
+					DefaultAmount:      60000 * tax.Baht,
+					AdminOverrideMax:   100000 * tax.Baht,
+					MinAmount:          10000 * tax.Baht,
 					TaxAllowanceType:   "personal",
 				},
 			},
@@ -335,10 +357,10 @@ func TestAdminHandler_Failed(t *testing.T) {
 			taxDeductions: []tax.TaxDeduction{
 				{
 					ID:                 3,
-					MaxDeductionAmount: 100000,
-					DefaultAmount:      60000,
-					AdminOverrideMax:   100000,
-					MinAmount:          10000,
+					MaxDeductionAmount: 100000 * tax.Baht,
+					DefaultAmount:      60000 * tax.Baht,
+					AdminOverrideMax:   100000 * tax.Baht,
+					MinAmount:          10000 * tax.Baht,
 					TaxAllowanceType:   "personal",
 				},
 			},
@@ -352,6 +374,209 @@ func TestAdminHandler_Failed(t *testing.T) {
 	})
 }
 
+func TestAdminHandler_TaxYear(t *testing.T) {
+	t.Run("Update deduction of a seeded year", func(t *testing.T) {
+		e := echo.New()
+		e.Validator = helper.NewValidator()
+
+		body := `{"amount": 70000, "taxYear": 2568}`
+		req := httptest.NewRequest(http.MethodPost, "/admin/deductions/:type", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+		c.SetPath("/admin/deduction/:type")
+		c.SetParamNames("type")
+		c.SetParamValues("personal")
+
+		h := New(MockAdmin{
+			taxDeductions: []tax.TaxDeduction{
+				{ID: 6, MaxDeductionAmount: 60000 * tax.Baht, AdminOverrideMax: 100000 * tax.Baht, MinAmount: 10000 * tax.Baht, TaxAllowanceType: "personal", TaxYear: 2568},
+			},
+			sqlResult: sqlmock.NewResult(0, 1),
+		})
+
+		err := h.AdminHandler(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Tax year not seeded", func(t *testing.T) {
+		e := echo.New()
+		e.Validator = helper.NewValidator()
+
+		body := `{"amount": 70000, "taxYear": 2569}`
+		req := httptest.NewRequest(http.MethodPost, "/admin/deductions/:type", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+		c.SetPath("/admin/deduction/:type")
+		c.SetParamNames("type")
+		c.SetParamValues("personal")
+
+		h := New(MockAdmin{
+			taxDeductions: []tax.TaxDeduction{
+				{ID: 6, MaxDeductionAmount: 60000 * tax.Baht, AdminOverrideMax: 100000 * tax.Baht, MinAmount: 10000 * tax.Baht, TaxAllowanceType: "personal", TaxYear: 2568},
+			},
+		})
+
+		err := h.AdminHandler(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "ยังไม่มีตารางภาษีของปี 2569", jsonMashal(rec.Body.Bytes()).Message)
+	})
+
+	t.Run("Deduction type not found", func(t *testing.T) {
+		e := echo.New()
+		e.Validator = helper.NewValidator()
+
+		body := `{"amount": 70000}`
+		req := httptest.NewRequest(http.MethodPost, "/admin/deductions/:type", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+		c.SetPath("/admin/deduction/:type")
+		c.SetParamNames("type")
+		c.SetParamValues("personal")
+
+		h := New(MockAdmin{})
+
+		err := h.AdminHandler(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestAdminPercentHandler(t *testing.T) {
+	donation := []tax.TaxDeduction{
+		{ID: 1, TaxAllowanceType: "donation", MaxPercentOfIncome: 10 * tax.Percent, AfterDeductions: true, TaxYear: 2567},
+	}
+
+	tests := []struct {
+		name            string
+		body            string
+		store           MockAdmin
+		expectedCode    int
+		expectedMessage string
+	}{
+		{
+			name:         "Update donation percent",
+			body:         `{"percent": 8}`,
+			store:        MockAdmin{taxDeductions: donation, sqlResult: sqlmock.NewResult(0, 1)},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:            "Percent is required",
+			body:            `{"taxYear": 2567}`,
+			store:           MockAdmin{taxDeductions: donation},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "Field Percent is required",
+		},
+		{
+			name:            "Percent over 100",
+			body:            `{"percent": 150}`,
+			store:           MockAdmin{taxDeductions: donation},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "กรุณากำหนดร้อยละระหว่าง 0 ถึง 100",
+		},
+		{
+			name:            "Invalid JSON",
+			body:            `{"percent":`,
+			store:           MockAdmin{taxDeductions: donation},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "Invalid JSON",
+		},
+		{
+			name:         "Tax year not seeded",
+			body:         `{"percent": 8, "taxYear": 2568}`,
+			store:        MockAdmin{taxDeductions: donation},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:            "Update error",
+			body:            `{"percent": 8}`,
+			store:           MockAdmin{taxDeductions: donation, updateError: errors.New("Update tax_deduction failed")},
+			expectedCode:    http.StatusInternalServerError,
+			expectedMessage: "Update tax_deduction failed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = helper.NewValidator()
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/deductions/:type/percent", strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			c := e.NewContext(req, rec)
+			c.SetPath("/admin/deductions/:type/percent")
+			c.SetParamNames("type")
+			c.SetParamValues("donation")
+
+			h := New(test.store)
+
+			err := h.AdminPercentHandler(c)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCode, rec.Code)
+			if test.expectedMessage != "" {
+				assert.Equal(t, test.expectedMessage, jsonMashal(rec.Body.Bytes()).Message)
+			}
+		})
+	}
+}
+
+func TestSeedTaxYearHandler(t *testing.T) {
+	tests := []struct {
+		name            string
+		year            string
+		body            string
+		seedError       error
+		expectedCode    int
+		expectedMessage string
+	}{
+		{name: "Copy latest year", year: "2568", expectedCode: http.StatusCreated},
+		{name: "New brackets", year: "2568", body: `{"rates":[{"lower_bound_income":0,"tax_rate":0},{"lower_bound_income":200001,"tax_rate":10}]}`, expectedCode: http.StatusCreated},
+		{name: "Invalid year", year: "abc", expectedCode: http.StatusBadRequest},
+		{name: "Invalid JSON", year: "2568", body: `{"rates":`, expectedCode: http.StatusBadRequest},
+		{name: "Single bracket", year: "2568", body: `{"rates":[{"lower_bound_income":0,"tax_rate":0}]}`, expectedCode: http.StatusBadRequest},
+		{name: "First bracket above 0", year: "2568", body: `{"rates":[{"lower_bound_income":1,"tax_rate":0},{"lower_bound_income":200001,"tax_rate":10}]}`, expectedCode: http.StatusBadRequest, expectedMessage: "ขั้นเงินได้แรกต้องเริ่มที่ 0"},
+		{name: "Brackets out of order", year: "2568", body: `{"rates":[{"lower_bound_income":0,"tax_rate":0},{"lower_bound_income":500001,"tax_rate":15},{"lower_bound_income":200001,"tax_rate":10}]}`, expectedCode: http.StatusBadRequest, expectedMessage: "กรุณาเรียงขั้นเงินได้จากน้อยไปมาก"},
+		{name: "Rate above 100", year: "2568", body: `{"rates":[{"lower_bound_income":0,"tax_rate":0},{"lower_bound_income":200001,"tax_rate":101}]}`, expectedCode: http.StatusBadRequest, expectedMessage: "กรุณากำหนดอัตราภาษีระหว่าง 0 ถึง 100"},
+		{name: "Negative rate", year: "2568", body: `{"rates":[{"lower_bound_income":0,"tax_rate":-5},{"lower_bound_income":200001,"tax_rate":10}]}`, expectedCode: http.StatusBadRequest, expectedMessage: "กรุณากำหนดอัตราภาษีระหว่าง 0 ถึง 100"},
+		{name: "Year exists", year: "2567", seedError: postgres.ErrTaxYearExists, expectedCode: http.StatusConflict},
+		{name: "Seed failed", year: "2568", seedError: errors.New("insert failed"), expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = helper.NewValidator()
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/tax-years/:year", strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			c := e.NewContext(req, rec)
+			c.SetPath("/admin/tax-years/:year")
+			c.SetParamNames("year")
+			c.SetParamValues(test.year)
+
+			h := New(MockAdmin{seedError: test.seedError})
+
+			err := h.SeedTaxYearHandler(c)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCode, rec.Code)
+			if test.expectedMessage != "" {
+				assert.Equal(t, test.expectedMessage, jsonMashal(rec.Body.Bytes()).Message)
+			}
+		})
+	}
+}
+
 func jsonMashal(b []byte) helper.ErrorMessage {
 	var eMsg helper.ErrorMessage
 	json.Unmarshal(b, &eMsg)
@@ -2,8 +2,11 @@ package admin
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/plakak13/assessment-tax/helper"
@@ -17,7 +20,9 @@ type Handler struct {
 
 type Storer interface {
 	UpdateTaxDeduction(s postgres.SettingTaxDeduction) (sql.Result, error)
-	TaxDeductionByType(allowanceTypes []string) ([]tax.TaxDeduction, error)
+	TaxDeductionByType(taxYear int, allowanceTypes []string) ([]tax.TaxDeduction, error)
+	UpdateTaxDeductionPercent(s postgres.SettingTaxDeduction) (sql.Result, error)
+	SeedTaxYear(taxYear int, rates []tax.TaxRate) error
 }
 
 func New(db Storer) *Handler {
@@ -38,18 +43,32 @@ func (h *Handler) AdminHandler(c echo.Context) error {
 		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
 
-	tRows, err := h.store.TaxDeductionByType([]string{param})
+	taxYear := sp.TaxYear
+	if taxYear == 0 {
+		taxYear = tax.CurrentTaxYear()
+	}
+
+	tRows, err := h.store.TaxDeductionByType(taxYear, []string{param})
 	if err != nil {
 		return helper.FailedHandler(c, err.Error())
 	}
 
+	if len(tRows) == 0 {
+		return helper.FailedHandler(c, "ไม่พบค่าลดหย่อนที่ต้องการกำหนด", http.StatusNotFound)
+	}
+
+	if sp.TaxYear != 0 && tRows[0].TaxYear != sp.TaxYear {
+		msg := fmt.Sprintf("ยังไม่มีตารางภาษีของปี %d", sp.TaxYear)
+		return helper.FailedHandler(c, msg, http.StatusNotFound)
+	}
+
 	if tRows[0].AdminOverrideMax < sp.Amount {
-		msg := fmt.Sprintf("ยอดที่กำหนดมีค่าเกินกว่า (%.1f) ที่สามารถกำหนดได้", tRows[0].AdminOverrideMax)
+		msg := fmt.Sprintf("ยอดที่กำหนดมีค่าเกินกว่า (%.1f) ที่สามารถกำหนดได้", tRows[0].AdminOverrideMax.Float64())
 		return helper.FailedHandler(c, msg, http.StatusBadRequest)
 	}
 
 	if tRows[0].MinAmount > sp.Amount {
-		msg := fmt.Sprintf("กรุณากำหนดมีค่าเกินกว่า (%.1f)", tRows[0].MinAmount)
+		msg := fmt.Sprintf("กรุณากำหนดมีค่าเกินกว่า (%.1f)", tRows[0].MinAmount.Float64())
 		return helper.FailedHandler(c, msg, http.StatusBadRequest)
 	}
 
@@ -67,13 +86,122 @@ func (h *Handler) AdminHandler(c echo.Context) error {
 		return helper.FailedHandler(c, err.Error())
 	}
 
-	response := make(map[string]interface{})
+	return helper.SuccessHandler(c, map[string]interface{}{responseKey(param): sp.Amount})
+}
+
+// responseKey names the updated amount after its allowance type in camel case,
+// k-receipt as kReceipt, except personal, which is personalDeduction.
+func responseKey(allowanceType string) string {
+	if allowanceType == "personal" {
+		return "personalDeduction"
+	}
+
+	parts := strings.Split(allowanceType, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// AdminPercentHandler sets the percent-of-income ceiling of a deduction, such
+// as the 10% limit on donations.
+func (h *Handler) AdminPercentHandler(c echo.Context) error {
+	ps := new(PercentSetting)
+	param := c.Param("type")
+
+	if err := c.Bind(ps); err != nil {
+		return helper.FailedHandler(c, "Invalid JSON", http.StatusBadRequest)
+	}
+
+	if err := c.Validate(ps); err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	}
+
+	if ps.Percent < 0 || ps.Percent > 100*tax.Percent {
+		return helper.FailedHandler(c, "กรุณากำหนดร้อยละระหว่าง 0 ถึง 100", http.StatusBadRequest)
+	}
+
+	taxYear := ps.TaxYear
+	if taxYear == 0 {
+		taxYear = tax.CurrentTaxYear()
+	}
+
+	tRows, err := h.store.TaxDeductionByType(taxYear, []string{param})
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+
+	if len(tRows) == 0 {
+		return helper.FailedHandler(c, "ไม่พบค่าลดหย่อนที่ต้องการกำหนด", http.StatusNotFound)
+	}
+
+	if ps.TaxYear != 0 && tRows[0].TaxYear != ps.TaxYear {
+		msg := fmt.Sprintf("ยังไม่มีตารางภาษีของปี %d", ps.TaxYear)
+		return helper.FailedHandler(c, msg, http.StatusNotFound)
+	}
+
+	row, err := h.store.UpdateTaxDeductionPercent(postgres.SettingTaxDeduction{
+		ID:      tRows[0].ID,
+		Percent: ps.Percent,
+	})
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+	_, err = row.RowsAffected()
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+
+	return helper.SuccessHandler(c, map[string]interface{}{"maxPercentOfIncome": ps.Percent})
+}
+
+func (h *Handler) SeedTaxYearHandler(c echo.Context) error {
+	ts := new(TaxYearSetting)
+
+	taxYear, err := strconv.Atoi(c.Param("year"))
+	if err != nil || taxYear < 2500 {
+		return helper.FailedHandler(c, "Invalid tax year", http.StatusBadRequest)
+	}
+
+	if err := c.Bind(ts); err != nil {
+		return helper.FailedHandler(c, "Invalid JSON", http.StatusBadRequest)
+	}
+
+	if err := c.Validate(ts); err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	}
 
-	if param == "personal" {
-		response["personalDeduction"] = sp.Amount
-	} else {
-		response["kReceipt"] = sp.Amount
+	if err := validationRates(ts.Rates); err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
 
-	return helper.SuccessHandler(c, response)
+	err = h.store.SeedTaxYear(taxYear, ts.Rates)
+	if errors.Is(err, postgres.ErrTaxYearExists) {
+		msg := fmt.Sprintf("ตารางภาษีของปี %d มีอยู่แล้ว", taxYear)
+		return helper.FailedHandler(c, msg, http.StatusConflict)
+	}
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+
+	return helper.SuccessHandler(c, map[string]interface{}{"taxYear": taxYear}, http.StatusCreated)
+}
+
+// validationRates checks new brackets: the first starts at 0, each starts
+// above the one before and every rate is between 0 and 100.
+func validationRates(rates []tax.TaxRate) error {
+	for i, r := range rates {
+		if i == 0 && r.LowerBoundIncome != 0 {
+			return errors.New("ขั้นเงินได้แรกต้องเริ่มที่ 0")
+		}
+		if i > 0 && r.LowerBoundIncome <= rates[i-1].LowerBoundIncome {
+			return errors.New("กรุณาเรียงขั้นเงินได้จากน้อยไปมาก")
+		}
+		if r.TaxRate < 0 || r.TaxRate > 100*tax.Percent {
+			return errors.New("กรุณากำหนดอัตราภาษีระหว่าง 0 ถึง 100")
+		}
+	}
+	return nil
 }
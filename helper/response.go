@@ -1,7 +1,11 @@
 package helper
 
 import (
+	"encoding/csv"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
@@ -26,3 +30,47 @@ func FailedHandler(c echo.Context, errorMsg string, statusCode ...int) error {
 	}
 	return c.JSON(code, ErrorMessage{Message: errorMsg})
 }
+
+// Spreadsheet formats a handler can offer in place of JSON.
+const (
+	MIMETextCSV = "text/csv"
+	MIMEXLSX    = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	MIMEExcel   = "application/vnd.ms-excel"
+)
+
+// Accepts returns the first type in the Accept header that is one of offers,
+// or "" when the client did not ask for any of them.
+func Accepts(c echo.Context, offers ...string) string {
+	for _, accept := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		mime, _, _ := strings.Cut(accept, ";")
+		mime = strings.TrimSpace(mime)
+		for _, offer := range offers {
+			if strings.EqualFold(mime, offer) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// CSVHandler starts the file with a byte order mark so Excel reads it as
+// UTF-8.
+func CSVHandler(c echo.Context, filename string, rows [][]string) error {
+	attachment(c, filename, MIMETextCSV)
+	if _, err := io.WriteString(c.Response(), "\ufeff"); err != nil {
+		return err
+	}
+	w := csv.NewWriter(c.Response())
+	return w.WriteAll(rows)
+}
+
+func XLSXHandler(c echo.Context, filename string, rows [][]string) error {
+	attachment(c, filename, MIMEXLSX)
+	return WriteXLSX(c.Response(), rows)
+}
+
+func attachment(c echo.Context, filename string, mime string) {
+	c.Response().Header().Set(echo.HeaderContentType, mime)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	c.Response().WriteHeader(http.StatusOK)
+}
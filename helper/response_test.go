@@ -41,3 +41,42 @@ func TestFailedHandler(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.JSONEq(t, `{"message":"error message"}`, rec.Body.String())
 }
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		expected string
+	}{
+		{name: "no header", accept: "", expected: ""},
+		{name: "json", accept: "application/json", expected: ""},
+		{name: "csv", accept: "text/csv", expected: MIMETextCSV},
+		{name: "first offered type wins", accept: "application/json, " + MIMEXLSX + ";q=0.9, text/csv", expected: MIMEXLSX},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAccept, test.accept)
+			c := e.NewContext(req, httptest.NewRecorder())
+
+			assert.Equal(t, test.expected, Accepts(c, MIMETextCSV, MIMEXLSX))
+		})
+	}
+}
+
+func TestCSVHandler(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := CSVHandler(c, "taxes.csv", [][]string{{"totalIncome", "tax"}, {"500000", "29000.00"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMETextCSV, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename="taxes.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, "\ufefftotalIncome,tax\n500000,29000.00\n", rec.Body.String())
+}
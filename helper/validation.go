@@ -17,6 +17,9 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 		var msg []string
 		for _, e := range err.(validator.ValidationErrors) {
 			eMsg := fmt.Sprintf("Field %s is %s", e.Field(), e.ActualTag())
+			if e.Param() != "" {
+				eMsg += " " + e.Param()
+			}
 			msg = append(msg, eMsg)
 		}
 		return errors.New(strings.Join(msg, ", "))
@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := WriteXLSX(&buf, [][]string{
+		{"employeeId", "tax"},
+		{"0001", "29000.00"},
+		{"<a&b>", "-1"},
+	})
+	assert.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+
+	var names []string
+	var sheet []byte
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			sheet, _ = io.ReadAll(r)
+		}
+	}
+
+	assert.ElementsMatch(t, []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"}, names)
+	assert.Contains(t, string(sheet), `<c r="A2" t="inlineStr"><is><t xml:space="preserve">0001</t></is></c><c r="B2"><v>29000.00</v></c>`)
+	assert.Contains(t, string(sheet), `<t xml:space="preserve">&lt;a&amp;b&gt;</t>`)
+	assert.Contains(t, string(sheet), `<c r="B3"><v>-1</v></c>`)
+}
+
+func TestColumn(t *testing.T) {
+	assert.Equal(t, "A", column(0))
+	assert.Equal(t, "Z", column(25))
+	assert.Equal(t, "AA", column(26))
+	assert.Equal(t, "AZ", column(51))
+	assert.Equal(t, "BA", column(52))
+}
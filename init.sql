@@ -1,12 +1,25 @@
 DROP TYPE IF EXISTS tax_allowance_type; 
-CREATE TYPE tax_allowance_type AS ENUM ('donation','k-receipt','personal'); 
+CREATE TYPE tax_allowance_type AS ENUM ('donation','donation-education','k-receipt','personal','spouse','child','child-2561','parent','disabled',
+'life-insurance','health-insurance','provident-fund','rmf','ssf','gpf','pension-insurance','social-security','home-loan','thai-esg'); 
 
 CREATE TABLE IF NOT EXISTS tax_rate (
 id SERIAL PRIMARY KEY,
 lower_bound_income DECIMAL (10,2) NOT NULL,
 tax_rate DECIMAL (10,2) NOT NULL,
+tax_year INT NOT NULL,
 created_at TIMESTAMP NOT NULL DEFAULT now(),
-updated_at TIMESTAMP NULL DEFAULT NULL); 
+updated_at TIMESTAMP NULL DEFAULT NULL,
+UNIQUE (tax_year, lower_bound_income)); 
+
+CREATE TABLE IF NOT EXISTS tax_deduction_group (
+id SERIAL PRIMARY KEY,
+name VARCHAR (32) NOT NULL,
+max_amount DECIMAL (18,2) NOT NULL,
+max_percent_of_income DECIMAL (5,2) NOT NULL DEFAULT 0,
+tax_year INT NOT NULL,
+created_at TIMESTAMP NOT NULL DEFAULT now(),
+updated_at TIMESTAMP NULL DEFAULT NULL,
+UNIQUE (tax_year, name)); 
 
 CREATE TABLE IF NOT EXISTS tax_deduction (
 id SERIAL PRIMARY KEY,
@@ -15,9 +28,32 @@ default_amount DECIMAL (18,2) NOT NULL,
 admin_override_max DECIMAL (18,2) NOT NULL,
 min_amount DECIMAL (18,2) NOT NULL,
 tax_allowance_type tax_allowance_type NOT NULL,
+tax_year INT NOT NULL,
+max_percent_of_income DECIMAL (5,2) NOT NULL DEFAULT 0,
+per_unit BOOLEAN NOT NULL DEFAULT FALSE,
+max_units INT NOT NULL DEFAULT 0,
+deduction_group VARCHAR (32) NULL,
+after_deductions BOOLEAN NOT NULL DEFAULT FALSE,
+deduction_rate DECIMAL (6,2) NOT NULL DEFAULT 100,
+halve_for_half_year BOOLEAN NOT NULL DEFAULT FALSE,
+created_at TIMESTAMP NOT NULL DEFAULT now(),
+updated_at TIMESTAMP NULL DEFAULT NULL,
+UNIQUE (tax_year, tax_allowance_type)); 
+
+CREATE TABLE IF NOT EXISTS tax_job (
+id VARCHAR (32) PRIMARY KEY,
+status VARCHAR (16) NOT NULL,
+input BYTEA NULL,
+rows_total INT NOT NULL DEFAULT 0,
+rows_done INT NOT NULL DEFAULT 0,
+error TEXT NULL,
+result JSONB NULL,
+sheet JSONB NULL,
 created_at TIMESTAMP NOT NULL DEFAULT now(),
 updated_at TIMESTAMP NULL DEFAULT NULL); 
 
+CREATE INDEX IF NOT EXISTS tax_job_status ON tax_job (status); 
+
 CREATE OR REPLACE FUNCTION update_updated_at_column () 
 RETURNS TRIGGER AS $$ 
 BEGIN 
@@ -32,14 +68,46 @@ UPDATE ON tax_rate FOR EACH ROW EXECUTE FUNCTION update_updated_at_column ();
 CREATE TRIGGER update_taxdeduction_updated_at BEFORE 
 UPDATE ON tax_deduction FOR EACH ROW EXECUTE FUNCTION update_updated_at_column (); 
 
-INSERT INTO "tax_rate" ("lower_bound_income","tax_rate","created_at") VALUES 
-('0.00','0.00',now()),
-('150001.00','10.00',now()),
-('500001.00','15.00',now()),
-('1000001.00','20.00',now()),
-('2000001.00','35.00',now()); 
-
-INSERT INTO "tax_deduction" ("max_deduction_amount","default_amount","admin_override_max","min_amount","tax_allowance_type","created_at","updated_at") VALUES 
-('100000.00','0','0','0','donation',now(),NULL),
-('50000.00','50000.00','100000.00','1.00','k-receipt',now(),NULL),
-('60000.00','60000.00','100000.00','10000.00','personal',now(),NULL);
\ No newline at end of file
+CREATE TRIGGER update_taxdeductiongroup_updated_at BEFORE 
+UPDATE ON tax_deduction_group FOR EACH ROW EXECUTE FUNCTION update_updated_at_column (); 
+
+CREATE TRIGGER update_taxjob_updated_at BEFORE 
+UPDATE ON tax_job FOR EACH ROW EXECUTE FUNCTION update_updated_at_column (); 
+
+INSERT INTO "tax_rate" ("lower_bound_income","tax_rate","tax_year","created_at") VALUES 
+('0.00','0.00',2567,now()),
+('150001.00','10.00',2567,now()),
+('500001.00','15.00',2567,now()),
+('1000001.00','20.00',2567,now()),
+('2000001.00','35.00',2567,now()); 
+
+INSERT INTO "tax_deduction" ("max_deduction_amount","default_amount","admin_override_max","min_amount","tax_allowance_type","tax_year","created_at","updated_at") VALUES 
+('50000.00','50000.00','100000.00','1.00','k-receipt',2567,now(),NULL),
+('60000.00','60000.00','100000.00','10000.00','personal',2567,now(),NULL);
+
+INSERT INTO "tax_deduction_group" ("name","max_amount","tax_year","created_at") VALUES 
+('insurance','100000.00',2567,now()),
+('retirement','500000.00',2567,now());
+
+INSERT INTO "tax_deduction" ("max_deduction_amount","default_amount","admin_override_max","min_amount","tax_allowance_type","tax_year","max_percent_of_income","per_unit","max_units","deduction_group","created_at") VALUES 
+('60000.00','60000.00','60000.00','0','spouse',2567,'0',TRUE,1,NULL,now()),
+('30000.00','30000.00','30000.00','0','child',2567,'0',TRUE,0,NULL,now()),
+('60000.00','60000.00','60000.00','0','child-2561',2567,'0',TRUE,0,NULL,now()),
+('30000.00','30000.00','30000.00','0','parent',2567,'0',TRUE,4,NULL,now()),
+('60000.00','60000.00','60000.00','0','disabled',2567,'0',TRUE,0,NULL,now()),
+('100000.00','0','100000.00','0','life-insurance',2567,'0',FALSE,0,'insurance',now()),
+('25000.00','0','25000.00','0','health-insurance',2567,'0',FALSE,0,'insurance',now()),
+('500000.00','0','500000.00','0','provident-fund',2567,'15.00',FALSE,0,'retirement',now()),
+('500000.00','0','500000.00','0','rmf',2567,'30.00',FALSE,0,'retirement',now()),
+('200000.00','0','200000.00','0','ssf',2567,'30.00',FALSE,0,'retirement',now()),
+('500000.00','0','500000.00','0','gpf',2567,'30.00',FALSE,0,'retirement',now()),
+('200000.00','0','200000.00','0','pension-insurance',2567,'15.00',FALSE,0,'retirement',now()),
+('9000.00','0','9000.00','0','social-security',2567,'0',FALSE,0,NULL,now()),
+('100000.00','0','100000.00','0','home-loan',2567,'0',FALSE,0,NULL,now()),
+('300000.00','0','300000.00','0','thai-esg',2567,'30.00',FALSE,0,NULL,now());
+
+INSERT INTO "tax_deduction" ("max_deduction_amount","default_amount","admin_override_max","min_amount","tax_allowance_type","tax_year","max_percent_of_income","after_deductions","deduction_rate","created_at") VALUES 
+('0','0','0','0','donation',2567,'10.00',TRUE,'100.00',now()),
+('0','0','0','0','donation-education',2567,'10.00',TRUE,'200.00',now());
+
+UPDATE "tax_deduction" SET "halve_for_half_year" = TRUE WHERE "tax_allowance_type" IN ('personal','spouse','child','child-2561','parent','disabled');
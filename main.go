@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
 	"syscall"
 	"time"
 
@@ -34,16 +35,36 @@ func main() {
 
 	handler := tax.New(p)
 	adminHandler := admin.New(p)
+	jobs := tax.NewJobs(p, p, e.Validator, runtime.NumCPU())
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		if err := jobs.Run(ctx); err != nil {
+			log.Fatal(err)
+		}
+	}()
 
 	g := e.Group("/tax")
 
 	g.POST("/calculations", handler.CalculationHandler)
 	g.POST("/calculations/upload-csv", handler.CalculationCSV)
+	g.POST("/calculations/reverse", handler.ReverseCalculationHandler)
+	g.POST("/calculations/compare", handler.CompareHandler)
+	g.POST("/withholdings", handler.WithholdingHandler)
+	g.POST("/optimizations", handler.OptimizationHandler)
+	g.POST("/jobs", jobs.CreateJobHandler)
+	g.GET("/jobs/:id", jobs.JobHandler)
+	g.GET("/jobs/:id/result", jobs.JobResultHandler)
 
 	a := e.Group("/admin")
 	a.Use(middleware.BasicAuth(authenticate))
 
 	a.POST("/deductions/:type", adminHandler.AdminHandler)
+	a.POST("/deductions/:type/percent", adminHandler.AdminPercentHandler)
+	a.POST("/tax-years/:year", adminHandler.SeedTaxYearHandler)
 
 	port := fmt.Sprintf(":%s", os.Getenv("PORT"))
 
@@ -57,6 +78,7 @@ func main() {
 	signal.Notify(shitdown, os.Interrupt, syscall.SIGTERM)
 	<-shitdown
 	fmt.Println("shutting down the server")
+	stop()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -64,6 +86,7 @@ func main() {
 	if err := e.Shutdown(ctx); err != nil {
 		e.Logger.Fatal(err)
 	}
+	<-jobsDone
 
 }
 
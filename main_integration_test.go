@@ -50,8 +50,8 @@ func uri(paths ...string) string {
 func TestCalculateTax(t *testing.T) {
 	t.Run("calculate tax no allowance", func(t *testing.T) {
 		calRequest := tax.TaxCalculation{
-			TotalIncome:    500000,
-			WithHoldingTax: 20000,
+			TotalIncome:    500000 * tax.Baht,
+			WithHoldingTax: 20000 * tax.Baht,
 			Allowances:     []tax.Allowance{},
 		}
 
@@ -67,16 +67,16 @@ func TestCalculateTax(t *testing.T) {
 
 	t.Run("calculate tax with donation and k-receipt", func(t *testing.T) {
 		calRequest := tax.TaxCalculation{
-			TotalIncome:    500000,
-			WithHoldingTax: 20000,
+			TotalIncome:    500000 * tax.Baht,
+			WithHoldingTax: 20000 * tax.Baht,
 			Allowances: []tax.Allowance{
 				{
 					AllowanceType: "donation",
-					Amount:        200000,
+					Amount:        200000 * tax.Baht,
 				},
 				{
 					AllowanceType: "k-receipt",
-					Amount:        50000,
+					Amount:        50000 * tax.Baht,
 				},
 			},
 		}
@@ -93,16 +93,16 @@ func TestCalculateTax(t *testing.T) {
 
 	t.Run("calculate withhoding tax is zero", func(t *testing.T) {
 		calRequest := tax.TaxCalculation{
-			TotalIncome:    500000,
+			TotalIncome:    500000 * tax.Baht,
 			WithHoldingTax: 0,
 			Allowances: []tax.Allowance{
 				{
 					AllowanceType: "donation",
-					Amount:        200000,
+					Amount:        200000 * tax.Baht,
 				},
 				{
 					AllowanceType: "k-receipt",
-					Amount:        50000,
+					Amount:        50000 * tax.Baht,
 				},
 			},
 		}
@@ -115,16 +115,16 @@ func TestCalculateTax(t *testing.T) {
 
 	t.Run("calculate tax withhoding more than total income", func(t *testing.T) {
 		calRequest := tax.TaxCalculation{
-			TotalIncome:    500000,
-			WithHoldingTax: 700000,
+			TotalIncome:    500000 * tax.Baht,
+			WithHoldingTax: 700000 * tax.Baht,
 			Allowances: []tax.Allowance{
 				{
 					AllowanceType: "donation",
-					Amount:        200000,
+					Amount:        200000 * tax.Baht,
 				},
 				{
 					AllowanceType: "k-receipt",
-					Amount:        50000,
+					Amount:        50000 * tax.Baht,
 				},
 			},
 		}
@@ -139,7 +139,7 @@ func TestCalculateTax(t *testing.T) {
 func TestAdminHandler_Integration(t *testing.T) {
 	t.Run("admin setting personal deduction success", func(t *testing.T) {
 		settingRequest := admin.Setting{
-			Amount: 60000,
+			Amount: 60000 * tax.Baht,
 		}
 
 		settingJSON, _ := json.Marshal(settingRequest)
@@ -154,7 +154,7 @@ func TestAdminHandler_Integration(t *testing.T) {
 
 	t.Run("admin setting personal deduction failed", func(t *testing.T) {
 		settingRequest := admin.Setting{
-			Amount: 6000,
+			Amount: 6000 * tax.Baht,
 		}
 
 		settingJSON, _ := json.Marshal(settingRequest)
@@ -1,10 +1,18 @@
 package postgres
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/plakak13/assessment-tax/tax"
+)
+
+var ErrTaxYearExists = errors.New("tax year already exists")
 
 type SettingTaxDeduction struct {
-	ID     int
-	Amount float64
+	ID      int
+	Amount  tax.Money
+	Percent tax.Rate
 }
 
 type UpdateTaxDeductionResponse struct {
@@ -23,3 +31,73 @@ func (p *Postgres) UpdateTaxDeduction(s SettingTaxDeduction) (sql.Result, error)
 
 	return row, nil
 }
+
+func (p *Postgres) UpdateTaxDeductionPercent(s SettingTaxDeduction) (sql.Result, error) {
+
+	query := `UPDATE tax_deduction SET max_percent_of_income = $1 WHERE id = $2 `
+
+	row, err := p.Db.Exec(query, s.Percent, s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return row, nil
+}
+
+// SeedTaxYear creates the rate and deduction tables for a tax year. The
+// deductions are copied from the latest year at or before it, or from the
+// earliest year when it comes before them all, so an amended return for an
+// earlier year does not pick up newer rules. The rates are copied the same way
+// unless new brackets are given.
+func (p *Postgres) SeedTaxYear(taxYear int, rates []tax.TaxRate) error {
+
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM tax_rate WHERE tax_year = $1)`, taxYear).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrTaxYearExists
+	}
+
+	if len(rates) == 0 {
+		query := `INSERT INTO tax_rate (lower_bound_income, tax_rate, tax_year)
+			SELECT lower_bound_income, tax_rate, $1 FROM tax_rate WHERE tax_year = COALESCE(
+			(SELECT MAX(tax_year) FROM tax_rate WHERE tax_year <= $1), (SELECT MIN(tax_year) FROM tax_rate))`
+		if _, err = tx.Exec(query, taxYear); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range rates {
+		query := `INSERT INTO tax_rate (lower_bound_income, tax_rate, tax_year) VALUES ($1, $2, $3)`
+		if _, err = tx.Exec(query, r.LowerBoundIncome, r.TaxRate, taxYear); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO tax_deduction_group (name, max_amount, max_percent_of_income, tax_year)
+		SELECT name, max_amount, max_percent_of_income, $1 FROM tax_deduction_group WHERE tax_year = COALESCE(
+			(SELECT MAX(tax_year) FROM tax_deduction_group WHERE tax_year <= $1), (SELECT MIN(tax_year) FROM tax_deduction_group))`
+	if _, err = tx.Exec(query, taxYear); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO tax_deduction (max_deduction_amount, default_amount, admin_override_max, min_amount, tax_allowance_type, tax_year,
+			max_percent_of_income, per_unit, max_units, deduction_group, after_deductions, deduction_rate, halve_for_half_year)
+		SELECT max_deduction_amount, default_amount, admin_override_max, min_amount, tax_allowance_type, $1,
+			max_percent_of_income, per_unit, max_units, deduction_group, after_deductions, deduction_rate, halve_for_half_year
+		FROM tax_deduction WHERE tax_year = COALESCE(
+			(SELECT MAX(tax_year) FROM tax_deduction WHERE tax_year <= $1), (SELECT MIN(tax_year) FROM tax_deduction))`
+	if _, err = tx.Exec(query, taxYear); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -2,9 +2,12 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
+	"regexp"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/plakak13/assessment-tax/tax"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,10 +23,10 @@ func TestUpdateTaxDeduction(t *testing.T) {
 
 		mockQuery := "UPDATE tax_deduction SET max_deduction_amount = \\$1 WHERE id = \\$2"
 		mock.ExpectExec(mockQuery).
-			WithArgs(70000.0, 1).
+			WithArgs("70000.00", 1).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		got, err := p.UpdateTaxDeduction(SettingTaxDeduction{ID: 1, Amount: 70000})
+		got, err := p.UpdateTaxDeduction(SettingTaxDeduction{ID: 1, Amount: 70000 * tax.Baht})
 
 		assert.NoError(t, err)
 
@@ -43,10 +46,10 @@ func TestUpdateTaxDeduction(t *testing.T) {
 
 		mockQuery := "UPDATE tax_deduction SET max_deduction_amount = \\$1 WHERE id = \\$2"
 		mock.ExpectExec(mockQuery).
-			WithArgs(70000.0, 1).
+			WithArgs("70000.00", 1).
 			WillReturnError(sql.ErrNoRows)
 
-		got, err := p.UpdateTaxDeduction(SettingTaxDeduction{ID: 1, Amount: 70000})
+		got, err := p.UpdateTaxDeduction(SettingTaxDeduction{ID: 1, Amount: 70000 * tax.Baht})
 
 		assert.Error(t, err)
 		assert.Nil(t, got)
@@ -55,3 +58,147 @@ func TestUpdateTaxDeduction(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestUpdateTaxDeductionPercent(t *testing.T) {
+	mockQuery := "UPDATE tax_deduction SET max_percent_of_income = \\$1 WHERE id = \\$2"
+
+	t.Run("Update percent success", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+
+		mock.ExpectExec(mockQuery).
+			WithArgs("10.00", 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		got, err := p.UpdateTaxDeductionPercent(SettingTaxDeduction{ID: 1, Percent: 10 * tax.Percent})
+
+		assert.NoError(t, err)
+		rf, err := got.RowsAffected()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, int(rf))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update percent failed", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+
+		mock.ExpectExec(mockQuery).
+			WithArgs("10.00", 1).
+			WillReturnError(sql.ErrNoRows)
+
+		got, err := p.UpdateTaxDeductionPercent(SettingTaxDeduction{ID: 1, Percent: 10 * tax.Percent})
+
+		assert.Error(t, err)
+		assert.Nil(t, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSeedTaxYear(t *testing.T) {
+	existsQuery := regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM tax_rate WHERE tax_year = $1)")
+	copyRatesQuery := "INSERT INTO tax_rate \\(lower_bound_income, tax_rate, tax_year\\)\\s+SELECT"
+	insertRateQuery := regexp.QuoteMeta("INSERT INTO tax_rate (lower_bound_income, tax_rate, tax_year) VALUES ($1, $2, $3)")
+	copyGroupsQuery := "INSERT INTO tax_deduction_group"
+	copyDeductionsQuery := "INSERT INTO tax_deduction \\("
+
+	t.Run("copy latest year", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(existsQuery).WithArgs(2568).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(copyRatesQuery).WithArgs(2568).WillReturnResult(sqlmock.NewResult(0, 5))
+		mock.ExpectExec(copyGroupsQuery).WithArgs(2568).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(copyDeductionsQuery).WithArgs(2568).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		err := p.SeedTaxYear(2568, nil)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("new brackets", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(existsQuery).WithArgs(2568).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(insertRateQuery).WithArgs("0.00", "0.00", 2568).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(insertRateQuery).WithArgs("200001.00", "10.00", 2568).WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec(copyGroupsQuery).WithArgs(2568).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(copyDeductionsQuery).WithArgs(2568).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		err := p.SeedTaxYear(2568, []tax.TaxRate{
+			{LowerBoundIncome: 0, TaxRate: 0},
+			{LowerBoundIncome: 200001 * tax.Baht, TaxRate: 10 * tax.Percent},
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("earlier year copies the rules in force then", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(existsQuery).WithArgs(2565).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(copyRatesQuery + ".*tax_year <= \\$1").WithArgs(2565).WillReturnResult(sqlmock.NewResult(0, 5))
+		mock.ExpectExec(copyGroupsQuery + ".*tax_year <= \\$1").WithArgs(2565).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(copyDeductionsQuery + ".*tax_year <= \\$1").WithArgs(2565).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		err := p.SeedTaxYear(2565, nil)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("tax year exists", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(existsQuery).WithArgs(2567).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		err := p.SeedTaxYear(2567, nil)
+
+		assert.ErrorIs(t, err, ErrTaxYearExists)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("copy deductions failed", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(existsQuery).WithArgs(2568).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(copyRatesQuery).WithArgs(2568).WillReturnResult(sqlmock.NewResult(0, 5))
+		mock.ExpectExec(copyGroupsQuery).WithArgs(2568).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(copyDeductionsQuery).WithArgs(2568).WillReturnError(errors.New("insert failed"))
+		mock.ExpectRollback()
+
+		err := p.SeedTaxYear(2568, nil)
+
+		assert.EqualError(t, err, "insert failed")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/plakak13/assessment-tax/tax"
+)
+
+func (p *Postgres) CreateJob(id string, input []byte) error {
+	query := `INSERT INTO tax_job (id, status, input) VALUES ($1, $2, $3)`
+	_, err := p.Db.Exec(query, id, tax.JobQueued, input)
+	return err
+}
+
+func (p *Postgres) Job(id string) (tax.Job, error) {
+	var j tax.Job
+	query := `SELECT id, status, rows_total, rows_done, COALESCE(error, ''), created_at, updated_at FROM tax_job WHERE id = $1`
+	err := p.Db.QueryRow(query, id).Scan(&j.ID, &j.Status, &j.RowsTotal, &j.RowsDone, &j.Error, &j.CreatedAt, &j.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tax.Job{}, tax.ErrJobNotFound
+	}
+	return j, err
+}
+
+func (p *Postgres) JobInput(id string) ([]byte, error) {
+	var input []byte
+	err := p.Db.QueryRow(`SELECT input FROM tax_job WHERE id = $1`, id).Scan(&input)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, tax.ErrJobNotFound
+	}
+	return input, err
+}
+
+// UnfinishedJobs lists the queued and interrupted jobs, oldest first.
+func (p *Postgres) UnfinishedJobs() ([]string, error) {
+	query := `SELECT id FROM tax_job WHERE status IN ($1, $2) ORDER BY created_at`
+	rows, err := p.Db.Query(query, tax.JobQueued, tax.JobRunning)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var ids []string
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ClaimJob marks a job running for this process. A queued job is free to
+// take; a running one only once nothing has written its progress for a
+// minute, so the process working it has stopped. Two processes never both
+// get the job.
+func (p *Postgres) ClaimJob(id string) (bool, error) {
+	query := `UPDATE tax_job SET status = $2 WHERE id = $1
+		AND (status = $3 OR (status = $2 AND updated_at < now() - interval '1 minute')) RETURNING id`
+	err := p.Db.QueryRow(query, id, tax.JobRunning, tax.JobQueued).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseJob puts a job this process is running back in the queue, for the
+// next process to claim when this one stops.
+func (p *Postgres) ReleaseJob(id string) error {
+	query := `UPDATE tax_job SET status = $2, rows_done = 0 WHERE id = $1 AND status = $3`
+	_, err := p.Db.Exec(query, id, tax.JobQueued, tax.JobRunning)
+	return err
+}
+
+func (p *Postgres) StartJob(id string, rowsTotal int) error {
+	query := `UPDATE tax_job SET status = $2, rows_total = $3, rows_done = 0 WHERE id = $1`
+	_, err := p.Db.Exec(query, id, tax.JobRunning, rowsTotal)
+	return err
+}
+
+// UpdateJobProgress only touches a running job, so a late update cannot move
+// a finished job back.
+func (p *Postgres) UpdateJobProgress(id string, rowsDone int) error {
+	query := `UPDATE tax_job SET rows_done = $2 WHERE id = $1 AND status = $3`
+	_, err := p.Db.Exec(query, id, rowsDone, tax.JobRunning)
+	return err
+}
+
+// FinishJob keeps the result and drops the uploaded file, which is no longer
+// needed.
+func (p *Postgres) FinishJob(id string, res tax.JobResult) error {
+	result, err := json.Marshal(res.Result)
+	if err != nil {
+		return err
+	}
+	sheet, err := json.Marshal(res.Sheet)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE tax_job SET status = $2, rows_done = rows_total, result = $3, sheet = $4, input = NULL WHERE id = $1`
+	_, err = p.Db.Exec(query, id, tax.JobDone, result, sheet)
+	return err
+}
+
+func (p *Postgres) FailJob(id string, message string) error {
+	query := `UPDATE tax_job SET status = $2, error = $3, input = NULL WHERE id = $1`
+	_, err := p.Db.Exec(query, id, tax.JobFailed, message)
+	return err
+}
+
+func (p *Postgres) JobResult(id string) (tax.JobResult, error) {
+	var result, sheet []byte
+	err := p.Db.QueryRow(`SELECT result, sheet FROM tax_job WHERE id = $1`, id).Scan(&result, &sheet)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tax.JobResult{}, tax.ErrJobNotFound
+	}
+	if err != nil {
+		return tax.JobResult{}, err
+	}
+
+	var res tax.JobResult
+	if err := json.Unmarshal(result, &res.Result); err != nil {
+		return tax.JobResult{}, err
+	}
+	if err := json.Unmarshal(sheet, &res.Sheet); err != nil {
+		return tax.JobResult{}, err
+	}
+	return res, nil
+}
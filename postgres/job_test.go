@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/plakak13/assessment-tax/tax"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateJob(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	p := Postgres{Db: db}
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO tax_job (id, status, input) VALUES ($1, $2, $3)")).
+		WithArgs("abc", tax.JobQueued, []byte("totalIncome\n500000")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := p.CreateJob("abc", []byte("totalIncome\n500000"))
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJob(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT id, status, rows_total, rows_done, COALESCE(error, ''), created_at, updated_at FROM tax_job WHERE id = $1")
+
+	t.Run("found", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+		p := Postgres{Db: db}
+		mock.ExpectQuery(query).
+			WithArgs("abc").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "status", "rows_total", "rows_done", "error", "created_at", "updated_at"}).
+				AddRow("abc", tax.JobRunning, 20000, 12500, "", created, nil))
+
+		job, err := p.Job("abc")
+
+		assert.NoError(t, err)
+		assert.Equal(t, tax.Job{ID: "abc", Status: tax.JobRunning, RowsTotal: 20000, RowsDone: 12500, CreatedAt: created}, job)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+		mock.ExpectQuery(query).WithArgs("abc").WillReturnError(sql.ErrNoRows)
+
+		_, err := p.Job("abc")
+
+		assert.ErrorIs(t, err, tax.ErrJobNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUnfinishedJobs(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	p := Postgres{Db: db}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM tax_job WHERE status IN ($1, $2) ORDER BY created_at")).
+		WithArgs(tax.JobQueued, tax.JobRunning).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a").AddRow("b"))
+
+	ids, err := p.UnfinishedJobs()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimJob(t *testing.T) {
+	query := "UPDATE tax_job SET status = \\$2 WHERE id = \\$1\\s+AND \\(status = \\$3 OR \\(status = \\$2 AND updated_at < now\\(\\) - interval '1 minute'\\)\\) RETURNING id"
+
+	t.Run("claimed", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+		mock.ExpectQuery(query).
+			WithArgs("abc", tax.JobRunning, tax.JobQueued).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("abc"))
+
+		claimed, err := p.ClaimJob("abc")
+
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("taken by another process", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+		mock.ExpectQuery(query).
+			WithArgs("abc", tax.JobRunning, tax.JobQueued).
+			WillReturnError(sql.ErrNoRows)
+
+		claimed, err := p.ClaimJob("abc")
+
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestReleaseJob(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	p := Postgres{Db: db}
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE tax_job SET status = $2, rows_done = 0 WHERE id = $1 AND status = $3")).
+		WithArgs("abc", tax.JobQueued, tax.JobRunning).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := p.ReleaseJob("abc")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFinishJob(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	p := Postgres{Db: db}
+	res := tax.JobResult{
+		Result: tax.TaxCSVCalculation{
+			Taxes:   []tax.TaxWithTotalIncome{{Row: 2, TotalIncome: 500000 * tax.Baht, TaxAmount: 29000 * tax.Baht}},
+			Summary: tax.CSVSummary{RowsOK: 1},
+		},
+		Sheet: [][]string{{"totalIncome", "tax"}, {"500000", "29000.00"}},
+	}
+	result := `{"taxes":[{"row":2,"totalIncome":500000.00,"tax":29000.00,"taxRefund":0.00}],"errors":null,"summary":{"rowsOk":1,"rowsFailed":0}}`
+	sheet := `[["totalIncome","tax"],["500000","29000.00"]]`
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE tax_job SET status = $2, rows_done = rows_total, result = $3, sheet = $4, input = NULL WHERE id = $1")).
+		WithArgs("abc", tax.JobDone, []byte(result), []byte(sheet)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT result, sheet FROM tax_job WHERE id = $1")).
+		WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows([]string{"result", "sheet"}).AddRow(result, sheet))
+
+	err := p.FinishJob("abc", res)
+	assert.NoError(t, err)
+
+	got, err := p.JobResult("abc")
+
+	assert.NoError(t, err)
+	assert.Equal(t, res, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateJobProgress(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	p := Postgres{Db: db}
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE tax_job SET rows_done = $2 WHERE id = $1 AND status = $3")).
+		WithArgs("abc", 500, tax.JobRunning).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := p.UpdateJobProgress("abc", 500)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -12,6 +12,13 @@ type Database interface {
 	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
+// queryer is what the read queries need, so they run the same on the pool and
+// inside a transaction.
+type queryer interface {
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
 type Postgres struct {
 	Db *sql.DB
 }
@@ -1,6 +1,8 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -9,47 +11,99 @@ import (
 
 type TaxDeduction struct {
 	ID                 int        `postgres:"id"`
-	MaxDeductionAmount float64    `postgres:"max_deduction_amount"`
-	DefaultAmount      float64    `postgres:"default_amount"`
-	AdminOverrideMax   float64    `postgres:"admin_override_max"`
-	MinAmount          float64    `postgres:"min_amount"`
+	MaxDeductionAmount tax.Money  `postgres:"max_deduction_amount"`
+	DefaultAmount      tax.Money  `postgres:"default_amount"`
+	AdminOverrideMax   tax.Money  `postgres:"admin_override_max"`
+	MinAmount          tax.Money  `postgres:"min_amount"`
 	TaxAllowanceType   string     `postgres:"tax_allowance_type"`
+	TaxYear            int        `postgres:"tax_year"`
+	MaxPercentOfIncome tax.Rate   `postgres:"max_percent_of_income"`
+	PerUnit            bool       `postgres:"per_unit"`
+	MaxUnits           int        `postgres:"max_units"`
+	DeductionGroup     *string    `postgres:"deduction_group"`
+	AfterDeductions    bool       `postgres:"after_deductions"`
+	DeductionRate      tax.Rate   `postgres:"deduction_rate"`
+	HalveForHalfYear   bool       `postgres:"halve_for_half_year"`
 	CreatedAt          time.Time  `postgres:"created_at"`
 	UpdatedAt          *time.Time `postgres:"updated_at"`
 }
 
 type TaxRate struct {
 	ID               int        `postgres:"id"`
-	LowerBoundIncome float64    `postgres:"lower_bound_income"`
-	TaxRate          float64    `postgres:"tax_rate"`
+	LowerBoundIncome tax.Money  `postgres:"lower_bound_income"`
+	TaxRate          tax.Rate   `postgres:"tax_rate"`
+	TaxYear          int        `postgres:"tax_year"`
 	CreatedAt        time.Time  `postgres:"created_at"`
 	UpdatedAt        *time.Time `postgres:"updated_at"`
 }
 
-func (p *Postgres) TaxDeductionByType(allowanceTypes []string) ([]tax.TaxDeduction, error) {
+// taxYearOf picks the table for the requested year, falling back to the latest
+// year before it, or the latest year on record when the request predates all
+// tables.
+const taxYearOf = `(SELECT COALESCE(MAX(tax_year) FILTER (WHERE tax_year <= $1), MAX(tax_year)) FROM %s)`
+
+func (p *Postgres) TaxDeductionByType(taxYear int, allowanceTypes []string) ([]tax.TaxDeduction, error) {
+	return taxDeductionByType(p.Db, taxYear, allowanceTypes)
+}
+
+func (p *Postgres) TaxRates(taxYear int) ([]tax.TaxRate, error) {
+	return taxRates(p.Db, taxYear)
+}
+
+// TaxSnapshot reads the rates and deductions of a year in one read-only
+// transaction, so an admin update committed in between cannot leave the two
+// tables from different moments.
+func (p *Postgres) TaxSnapshot(taxYear int, allowanceTypes []string) (tax.Snapshot, error) {
+	tx, err := p.Db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return tax.Snapshot{}, err
+	}
+	defer tx.Rollback()
+
+	tds, err := taxDeductionByType(tx, taxYear, allowanceTypes)
+	if err != nil {
+		return tax.Snapshot{}, err
+	}
+
+	trs, err := taxRates(tx, taxYear)
+	if err != nil {
+		return tax.Snapshot{}, err
+	}
+
+	return tax.Snapshot{Rates: trs, Deductions: tds}, tx.Commit()
+}
+
+// taxDeductionByType compares the types as text, so a type the enum does not
+// know, such as an unexpected CSV column, is just not found.
+func taxDeductionByType(db queryer, taxYear int, allowanceTypes []string) ([]tax.TaxDeduction, error) {
 
 	if len(allowanceTypes) == 0 {
 		return []tax.TaxDeduction{}, fmt.Errorf("please sent allowance type as least 1")
 	}
 
 	var td []tax.TaxDeduction
-	argsTax := make([]any, len(allowanceTypes))
+	argsTax := make([]any, len(allowanceTypes)+1)
+	argsTax[0] = taxYear
 
-	query := "SELECT id, max_deduction_amount, default_amount, admin_override_max, min_amount, tax_allowance_type FROM tax_deduction WHERE tax_allowance_type IN ("
+	query := "SELECT d.id, d.max_deduction_amount, d.default_amount, d.admin_override_max, d.min_amount, d.tax_allowance_type, d.tax_year, " +
+		"d.max_percent_of_income, d.per_unit, d.max_units, d.after_deductions, d.deduction_rate, d.halve_for_half_year, COALESCE(d.deduction_group, ''), COALESCE(g.max_amount, 0), COALESCE(g.max_percent_of_income, 0) " +
+		"FROM tax_deduction d LEFT JOIN tax_deduction_group g ON g.name = d.deduction_group AND g.tax_year = d.tax_year " +
+		"WHERE d.tax_year = " + fmt.Sprintf(taxYearOf, "tax_deduction") + " AND d.tax_allowance_type::text IN ("
 
 	for i, att := range allowanceTypes {
-		query += fmt.Sprintf("$%d", i+1)
-		argsTax[i] = att
+		query += fmt.Sprintf("$%d", i+2)
+		argsTax[i+1] = att
 		if i < len(allowanceTypes)-1 {
 			query += ", "
 		}
 	}
 	query += ")"
 
-	stmt, err := p.Db.Prepare(query)
+	stmt, err := db.Prepare(query)
 	if err != nil {
 		return td, err
 	}
+	defer stmt.Close()
 
 	rows, err := stmt.Query(argsTax...)
 	if err != nil {
@@ -67,6 +121,16 @@ func (p *Postgres) TaxDeductionByType(allowanceTypes []string) ([]tax.TaxDeducti
 			&t.AdminOverrideMax,
 			&t.MinAmount,
 			&t.TaxAllowanceType,
+			&t.TaxYear,
+			&t.MaxPercentOfIncome,
+			&t.PerUnit,
+			&t.MaxUnits,
+			&t.AfterDeductions,
+			&t.DeductionRate,
+			&t.HalveForHalfYear,
+			&t.DeductionGroup,
+			&t.GroupMaxAmount,
+			&t.GroupMaxPercentOfIncome,
 		)
 		if err != nil {
 			return td, err
@@ -78,15 +142,27 @@ func (p *Postgres) TaxDeductionByType(allowanceTypes []string) ([]tax.TaxDeducti
 			AdminOverrideMax:   t.AdminOverrideMax,
 			MinAmount:          t.MinAmount,
 			TaxAllowanceType:   t.TaxAllowanceType,
+			TaxYear:            t.TaxYear,
+			MaxPercentOfIncome: t.MaxPercentOfIncome,
+			PerUnit:            t.PerUnit,
+			MaxUnits:           t.MaxUnits,
+			AfterDeductions:    t.AfterDeductions,
+			DeductionRate:      t.DeductionRate,
+			DeductionGroup:     t.DeductionGroup,
+			GroupMaxAmount:     t.GroupMaxAmount,
+
+			GroupMaxPercentOfIncome: t.GroupMaxPercentOfIncome,
+			HalveForHalfYear:        t.HalveForHalfYear,
 		})
 	}
 
 	return td, nil
 }
 
-func (p *Postgres) TaxRates() ([]tax.TaxRate, error) {
-	query := `SELECT id, lower_bound_income, tax_rate FROM tax_rate`
-	rows, err := p.Db.Query(query)
+func taxRates(db queryer, taxYear int) ([]tax.TaxRate, error) {
+	query := `SELECT id, lower_bound_income, tax_rate, tax_year FROM tax_rate WHERE tax_year = ` +
+		fmt.Sprintf(taxYearOf, "tax_rate") + ` ORDER BY lower_bound_income`
+	rows, err := db.Query(query, taxYear)
 	if err != nil {
 		return nil, err
 	}
@@ -96,7 +172,7 @@ func (p *Postgres) TaxRates() ([]tax.TaxRate, error) {
 
 	for rows.Next() {
 		var t tax.TaxRate
-		err = rows.Scan(&t.ID, &t.LowerBoundIncome, &t.TaxRate)
+		err = rows.Scan(&t.ID, &t.LowerBoundIncome, &t.TaxRate, &t.TaxYear)
 		if err != nil {
 			return nil, err
 		}
@@ -104,6 +180,7 @@ func (p *Postgres) TaxRates() ([]tax.TaxRate, error) {
 			ID:               t.ID,
 			LowerBoundIncome: t.LowerBoundIncome,
 			TaxRate:          t.TaxRate,
+			TaxYear:          t.TaxYear,
 		})
 	}
 	return tr, nil
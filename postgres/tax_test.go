@@ -28,7 +28,7 @@ func TestTaxDeductionByType(t *testing.T) {
 		expectedRec := []TaxDeduction{
 			{
 				ID:                 1,
-				MaxDeductionAmount: 100000.00,
+				MaxDeductionAmount: 100000 * tax.Baht,
 				DefaultAmount:      0.00,
 				AdminOverrideMax:   0.00,
 				MinAmount:          0.00,
@@ -36,29 +36,40 @@ func TestTaxDeductionByType(t *testing.T) {
 			},
 			{
 				ID:                 2,
-				MaxDeductionAmount: 50000.00,
-				DefaultAmount:      50000.00,
-				AdminOverrideMax:   100000.00,
+				MaxDeductionAmount: 50000 * tax.Baht,
+				DefaultAmount:      50000 * tax.Baht,
+				AdminOverrideMax:   100000 * tax.Baht,
 				MinAmount:          0.00,
 				TaxAllowanceType:   "k-reciept",
 			},
+			{
+				ID:                 3,
+				MaxDeductionAmount: 500000 * tax.Baht,
+				AdminOverrideMax:   500000 * tax.Baht,
+				TaxAllowanceType:   "rmf",
+				MaxPercentOfIncome: 30 * tax.Percent,
+			},
 		}
-		allownceType := []string{"donation", "k-reciept"}
+		allownceType := []string{"donation", "k-reciept", "rmf"}
 
-		mockQuery := "SELECT id, max_deduction_amount, default_amount, admin_override_max, min_amount, tax_allowance_type FROM tax_deduction WHERE tax_allowance_type IN ()"
-		rows := sqlmock.NewRows([]string{"id", "max_deduction_amount", "default_amount", "admin_override_max", "min_amount", "tax_allowance_type"}).
-			AddRow(1, 100000.00, 0.00, 0.00, 0.00, "donation").
-			AddRow(2, 50000.00, 50000.00, 100000.00, 0.00, "k-reciept")
+		mockQuery := "SELECT d.id, d.max_deduction_amount, d.default_amount, d.admin_override_max, d.min_amount, d.tax_allowance_type, d.tax_year, "
+		rows := sqlmock.NewRows([]string{"id", "max_deduction_amount", "default_amount", "admin_override_max", "min_amount", "tax_allowance_type", "tax_year", "max_percent_of_income", "per_unit", "max_units", "after_deductions", "deduction_rate", "halve_for_half_year", "deduction_group", "group_max_amount", "group_max_percent_of_income"}).
+			AddRow(1, 100000.00, 0.00, 0.00, 0.00, "donation", 2567, 0.00, false, 0, false, 100.00, false, "", 0.00, 0.00).
+			AddRow(2, 50000.00, 50000.00, 100000.00, 0.00, "k-reciept", 2567, 0.00, false, 0, false, 100.00, false, "", 0.00, 0.00).
+			AddRow(3, 500000.00, 0.00, 500000.00, 0.00, "rmf", 2567, 30.00, false, 0, false, 100.00, false, "retirement", 500000.00, 0.00)
 
-		mock.ExpectPrepare(mockQuery).
+		mock.ExpectPrepare(mockQuery+".*d.tax_allowance_type::text IN \\(\\$2, \\$3, \\$4\\)").
 			ExpectQuery().
-			WithArgs(allownceType[0], allownceType[1]).
+			WithArgs(2567, allownceType[0], allownceType[1], allownceType[2]).
 			WillReturnRows(rows)
 
-		td, err := p.TaxDeductionByType(allownceType)
+		td, err := p.TaxDeductionByType(2567, allownceType)
 
 		assert.NoError(t, err)
 		assert.Equal(t, len(td), len(expectedRec), "unexpected length of tax deductions")
+		assert.Equal(t, 30*tax.Percent, td[2].MaxPercentOfIncome)
+		assert.Equal(t, "retirement", td[2].DeductionGroup)
+		assert.Equal(t, 500000*tax.Baht, td[2].GroupMaxAmount)
 		err = mock.ExpectationsWereMet()
 		assert.NoError(t, err, fmt.Sprintf("Unfulfilled expectations: %s", err))
 	})
@@ -67,7 +78,7 @@ func TestTaxDeductionByType(t *testing.T) {
 		defer db.Close()
 
 		p := Postgres{Db: db}
-		rows, err := p.TaxDeductionByType([]string{})
+		rows, err := p.TaxDeductionByType(2567, []string{})
 		if err == nil {
 			t.Errorf("expect error message but got %v", err)
 		}
@@ -82,12 +93,12 @@ func TestTaxDeductionByType(t *testing.T) {
 		defer db.Close()
 
 		p := Postgres{Db: db}
-		mockQuery := "SELECT id, max_deduction_amount, default_amount, admin_override_max, min_amount, tax_allowance_type FROM tax_deduction WHERE tax_allowance_type IN ()"
+		mockQuery := "SELECT d.id, d.max_deduction_amount, d.default_amount, d.admin_override_max, d.min_amount, d.tax_allowance_type, d.tax_year, "
 
 		expectedError := errors.New("failed to prepare statement")
 		mock.ExpectPrepare(mockQuery).WillReturnError(expectedError)
 
-		_, err := p.TaxDeductionByType([]string{"donation", "k-reciept"})
+		_, err := p.TaxDeductionByType(2567, []string{"donation", "k-reciept"})
 
 		assert.EqualError(t, err, expectedError.Error())
 
@@ -101,13 +112,13 @@ func TestTaxDeductionByType(t *testing.T) {
 		defer db.Close()
 
 		p := Postgres{Db: db}
-		mockQuery := "SELECT id, max_deduction_amount, default_amount, admin_override_max, min_amount, tax_allowance_type FROM tax_deduction WHERE tax_allowance_type IN ()"
+		mockQuery := "SELECT d.id, d.max_deduction_amount, d.default_amount, d.admin_override_max, d.min_amount, d.tax_allowance_type, d.tax_year, "
 
 		expectedError := errors.New("query error")
 
 		mock.ExpectPrepare(mockQuery).ExpectQuery().WithArgs().WillReturnError(expectedError)
 
-		_, err := p.TaxDeductionByType([]string{"donation", "k-reciept"})
+		_, err := p.TaxDeductionByType(2567, []string{"donation", "k-reciept"})
 
 		assert.EqualError(t, err, expectedError.Error())
 
@@ -121,17 +132,17 @@ func TestTaxDeductionByType(t *testing.T) {
 		defer db.Close()
 
 		p := Postgres{Db: db}
-		mockQuery := "SELECT id, max_deduction_amount, default_amount, admin_override_max, min_amount, tax_allowance_type FROM tax_deduction WHERE tax_allowance_type IN ()"
-		rows := sqlmock.NewRows([]string{"id", "max_deduction_amount", "default_amount", "admin_override_max", "min_amount", "tax_allowance_type"}).
-			AddRow(nil, nil, 50000.00, 100000.00, 0.00, "k-reciept").
+		mockQuery := "SELECT d.id, d.max_deduction_amount, d.default_amount, d.admin_override_max, d.min_amount, d.tax_allowance_type, d.tax_year, "
+		rows := sqlmock.NewRows([]string{"id", "max_deduction_amount", "default_amount", "admin_override_max", "min_amount", "tax_allowance_type", "tax_year", "max_percent_of_income", "per_unit", "max_units", "after_deductions", "deduction_rate", "halve_for_half_year", "deduction_group", "group_max_amount", "group_max_percent_of_income"}).
+			AddRow(nil, nil, 50000.00, 100000.00, 0.00, "k-reciept", 2567, 0.00, false, 0, false, 100.00, false, "", 0.00, 0.00).
 			RowError(2, errors.New("error row"))
 
 		mock.ExpectPrepare(mockQuery).
 			ExpectQuery().
-			WithArgs("donation", "k-reciept").
+			WithArgs(2567, "donation", "k-reciept").
 			WillReturnRows(rows)
 
-		_, err := p.TaxDeductionByType([]string{"donation", "k-reciept"})
+		_, err := p.TaxDeductionByType(2567, []string{"donation", "k-reciept"})
 
 		assert.Error(t, err)
 		err = mock.ExpectationsWereMet()
@@ -152,24 +163,25 @@ func TestTaxRates(t *testing.T) {
 		expectedTaxRate := []tax.TaxRate{
 			{
 				ID:               1,
-				LowerBoundIncome: 150001.00,
-				TaxRate:          10,
+				LowerBoundIncome: 150001 * tax.Baht,
+				TaxRate:          10 * tax.Percent,
 			},
 			{
 				ID:               2,
-				LowerBoundIncome: 500001.00,
-				TaxRate:          115,
+				LowerBoundIncome: 500001 * tax.Baht,
+				TaxRate:          115 * tax.Percent,
 			},
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "lower_bound_income", "tax_rate"}).
-			AddRow(expectedTaxRate[0].ID, expectedTaxRate[0].LowerBoundIncome, expectedTaxRate[0].TaxRate).
-			AddRow(expectedTaxRate[1].ID, expectedTaxRate[0].LowerBoundIncome, expectedTaxRate[0].TaxRate)
+		rows := sqlmock.NewRows([]string{"id", "lower_bound_income", "tax_rate", "tax_year"}).
+			AddRow(expectedTaxRate[0].ID, expectedTaxRate[0].LowerBoundIncome, expectedTaxRate[0].TaxRate, 2567).
+			AddRow(expectedTaxRate[1].ID, expectedTaxRate[0].LowerBoundIncome, expectedTaxRate[0].TaxRate, 2567)
 
-		mock.ExpectQuery("SELECT id, lower_bound_income, tax_rate FROM tax_rate").
+		mock.ExpectQuery("SELECT id, lower_bound_income, tax_rate, tax_year FROM tax_rate").
+			WithArgs(2567).
 			WillReturnRows(rows)
 
-		trs, err := postgres.TaxRates()
+		trs, err := postgres.TaxRates(2567)
 		assert.NoError(t, err)
 		assert.Equal(t, len(trs), len(expectedTaxRate))
 
@@ -184,10 +196,10 @@ func TestTaxRates(t *testing.T) {
 
 		postgres := Postgres{Db: db}
 
-		mock.ExpectQuery("SELECT id, lower_bound_income, tax_rate FROM tax_rate").
+		mock.ExpectQuery("SELECT id, lower_bound_income, tax_rate, tax_year FROM tax_rate").
 			WillReturnError(errors.New("query error"))
 
-		got, err := postgres.TaxRates()
+		got, err := postgres.TaxRates(2567)
 		assert.Nil(t, got)
 		assert.Error(t, err, "query error")
 
@@ -203,16 +215,63 @@ func TestTaxRates(t *testing.T) {
 			AddRow(nil, nil).
 			RowError(1, errors.New("error rows"))
 
-		mock.ExpectQuery("SELECT id, lower_bound_income, tax_rate FROM tax_rate").
+		mock.ExpectQuery("SELECT id, lower_bound_income, tax_rate, tax_year FROM tax_rate").
 			WillReturnRows(rows)
 
-		got, err := postgres.TaxRates()
+		got, err := postgres.TaxRates(2567)
 		assert.Nil(t, got)
 		assert.Error(t, err, "should be error")
 
 	})
 }
 
+func TestTaxSnapshot(t *testing.T) {
+	deductionQuery := "SELECT d.id, d.max_deduction_amount, d.default_amount, d.admin_override_max, d.min_amount, d.tax_allowance_type, d.tax_year, "
+	rateQuery := "SELECT id, lower_bound_income, tax_rate, tax_year FROM tax_rate"
+
+	t.Run("reads both tables in one transaction", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+		mock.ExpectBegin()
+		mock.ExpectPrepare(deductionQuery).
+			ExpectQuery().
+			WithArgs(2567, "personal").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "max_deduction_amount", "default_amount", "admin_override_max", "min_amount", "tax_allowance_type", "tax_year", "max_percent_of_income", "per_unit", "max_units", "after_deductions", "deduction_rate", "halve_for_half_year", "deduction_group", "group_max_amount", "group_max_percent_of_income"}).
+				AddRow(1, 60000.00, 0.00, 0.00, 0.00, "personal", 2567, 0.00, false, 0, false, 100.00, true, "", 0.00, 0.00))
+		mock.ExpectQuery(rateQuery).
+			WithArgs(2567).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "lower_bound_income", "tax_rate", "tax_year"}).
+				AddRow(1, 0.00, 0.00, 2567).
+				AddRow(2, 150001.00, 10.00, 2567))
+		mock.ExpectCommit()
+
+		snap, err := p.TaxSnapshot(2567, []string{"personal"})
+
+		assert.NoError(t, err)
+		assert.Len(t, snap.Deductions, 1)
+		assert.True(t, snap.Deductions[0].HalveForHalfYear)
+		assert.Len(t, snap.Rates, 2)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rolls back when a read fails", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+
+		p := Postgres{Db: db}
+		mock.ExpectBegin()
+		mock.ExpectPrepare(deductionQuery).WillReturnError(errors.New("failed to prepare statement"))
+		mock.ExpectRollback()
+
+		_, err := p.TaxSnapshot(2567, []string{"personal"})
+
+		assert.EqualError(t, err, "failed to prepare statement")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func NewMock() (*sql.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
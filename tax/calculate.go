@@ -0,0 +1,240 @@
+package tax
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// Calculate runs the full tax calculation for one taxpayer against the given
+// rate table and deduction rows. It does not touch the store, so the HTTP and
+// CSV handlers can share it. A spouse or dividends also get the ways of filing
+// them compared.
+func Calculate(tc TaxCalculation, rates []TaxRate, tds []TaxDeduction) (CalculationResponse, error) {
+	res, err := calculate(tc, rates, tds)
+	if err != nil {
+		return CalculationResponse{}, err
+	}
+
+	if tc.Spouse != nil {
+		if res.Filing, err = fileSpouse(tc, res, rates, tds); err != nil {
+			return CalculationResponse{}, err
+		}
+	}
+	if len(tc.Dividends) > 0 {
+		if res.Dividends, err = compareDividends(tc, rates, tds); err != nil {
+			return CalculationResponse{}, err
+		}
+	}
+
+	return res, nil
+}
+
+// calculate is Calculate for the taxpayer alone, as they asked to file.
+func calculate(tc TaxCalculation, rates []TaxRate, tds []TaxDeduction) (CalculationResponse, error) {
+	expenses, err := incomeExpenses(tc.Incomes)
+	if err != nil {
+		return CalculationResponse{}, err
+	}
+
+	// A bare TotalIncome is taken as income already net of expenses.
+	if len(tc.Incomes) > 0 {
+		var gross Money
+		for _, in := range tc.Incomes {
+			gross += in.Amount
+		}
+		if tc.TotalIncome != 0 && tc.TotalIncome != gross {
+			return CalculationResponse{}, errors.New("total income does not match the sum of incomes")
+		}
+		tc.TotalIncome = gross
+	}
+
+	rows := tds
+	if tc.FilingType == FilingTypeHalfYear {
+		rows = halfYearDeductions(tds)
+	}
+
+	if err := validationTax(rows, tc); err != nil {
+		return CalculationResponse{}, err
+	}
+	if err := validationFilingType(tc); err != nil {
+		return CalculationResponse{}, err
+	}
+	if err := checkSpouseClaim(tc); err != nil {
+		return CalculationResponse{}, err
+	}
+	if err := validationDividends(tc.Dividends); err != nil {
+		return CalculationResponse{}, err
+	}
+	if err := validationForeignIncomes(tc); err != nil {
+		return CalculationResponse{}, err
+	}
+	if err := validationPrepayments(tc); err != nil {
+		return CalculationResponse{}, err
+	}
+
+	assessable := tc.TotalIncome
+	credits := map[string]Money{
+		PrepaymentWht:      prepaid(tc, PrepaymentWht),
+		PrepaymentHalfYear: prepaid(tc, PrepaymentHalfYear),
+	}
+	if tc.IncludeDividends {
+		dividends, dividendCredits := includedDividends(tc.Dividends)
+		assessable += dividends
+		credits[PrepaymentDividend] = dividendCredits
+	}
+	for _, f := range tc.ForeignIncomes {
+		assessable += f.Amount
+	}
+
+	income := assessable
+	for _, e := range expenses {
+		income -= e.Expense
+	}
+
+	rates = sortedRates(rates)
+	deductions := deductAllowances(rows, tc.Allowances, assessable, income)
+
+	for _, d := range deductions {
+		income -= d.Allowed
+	}
+
+	bracketTax, bandTaxes := progressiveTax(income, rates)
+	minTax := minimumTax(tc.Incomes, tc.FilingType)
+
+	taxDue, method := bracketTax, TaxMethodBracket
+	if minTax > bracketTax {
+		taxDue, method = minTax, TaxMethodMinimum
+	}
+
+	taxCredit := taxDue
+	taxLevels := taxLevelDetails(rates, bandTaxes)
+	foreignCredits := foreignTaxCredits(tc.ForeignIncomes, taxDue, assessable)
+	for _, f := range foreignCredits {
+		taxCredit -= f.Credit
+		taxLevels = append(taxLevels, TaxLevelInfo{Level: "เครดิตภาษีต่างประเทศ " + f.Country, Tax: -f.Credit})
+	}
+
+	prepayments := prepaymentTotals(credits)
+	for _, p := range prepayments {
+		taxCredit -= p.Amount
+	}
+	taxRefund, taxPayable := refundTax(taxCredit)
+
+	res := CalculationResponse{
+		Tax:       taxPayable,
+		TaxRefund: taxRefund,
+		TaxLevel:  taxLevels,
+
+		NetIncome:       income,
+		TotalDeductions: assessable - income,
+		TaxBeforeWht:    taxDue,
+		EffectiveRate:   effectiveRate(taxDue, assessable),
+		MarginalRate:    marginalRate(income, rates),
+
+		BracketTax: bracketTax,
+		MinimumTax: minTax,
+		TaxMethod:  method,
+
+		Deductions: deductions,
+		Expenses:   expenses,
+
+		ForeignTaxCredits: foreignCredits,
+		Prepayments:       prepayments,
+	}
+	if tc.LateFiling != nil {
+		if res.LateCharges, err = lateCharges(tc.LateFiling, taxPayable); err != nil {
+			return CalculationResponse{}, err
+		}
+	}
+
+	return res, nil
+}
+
+// progressiveTax walks every bracket and taxes the slice of income that falls
+// inside it. It returns the total and the tax of each bracket in rate order.
+func progressiveTax(income Money, rates []TaxRate) (Money, []Money) {
+	var total Money
+	bandTaxes := make([]Money, len(rates))
+
+	for i, r := range rates {
+		floor := bandFloor(r)
+		if income <= floor {
+			break
+		}
+
+		upper := income
+		if i+1 < len(rates) {
+			upper = min(income, bandFloor(rates[i+1]))
+		}
+
+		bandTaxes[i] = (upper - floor).MulRate(r.TaxRate)
+		total += bandTaxes[i]
+	}
+	return total, bandTaxes
+}
+
+// effectiveRate is the tax due as a share of gross income.
+func effectiveRate(tax, income Money) Rate {
+	if income <= 0 {
+		return 0
+	}
+	return Rate(tax.MulDiv(int64(100*Percent), int64(income)))
+}
+
+// marginalRate is the rate of the bracket the last baht of net income falls
+// in.
+func marginalRate(income Money, rates []TaxRate) Rate {
+	var rate Rate
+	for _, r := range rates {
+		if income <= bandFloor(r) {
+			break
+		}
+		rate = r.TaxRate
+	}
+	return rate
+}
+
+// bandFloor is the income already covered by the brackets below r. Rows are
+// stored with inclusive lower bounds (150,001), so the bracket starts taxing
+// above 150,000.
+func bandFloor(r TaxRate) Money {
+	return max(r.LowerBoundIncome-Baht, 0)
+}
+
+func sortedRates(rates []TaxRate) []TaxRate {
+	sorted := slices.Clone(rates)
+	slices.SortFunc(sorted, func(a, b TaxRate) int {
+		return cmp.Compare(a.LowerBoundIncome, b.LowerBoundIncome)
+	})
+	return sorted
+}
+
+func taxLevelDetails(taxRates []TaxRate, bandTaxes []Money) []TaxLevelInfo {
+	var taxLevels []TaxLevelInfo
+	p := message.NewPrinter(language.English)
+	for i, v := range taxRates {
+		if i+1 != len(taxRates) {
+			tFormat := p.Sprintf("%v-%v", number.Decimal(v.LowerBoundIncome.Float64()), number.Decimal((taxRates[i+1].LowerBoundIncome - Baht).Float64()))
+
+			taxLevels = append(taxLevels, TaxLevelInfo{
+				Tax:   bandTaxes[i],
+				Level: tFormat,
+			})
+
+		} else {
+			lastT := p.Sprintf("%v ขึ้นไป", number.Decimal(v.LowerBoundIncome.Float64()))
+
+			taxLevels = append(taxLevels, TaxLevelInfo{
+				Tax:   bandTaxes[i],
+				Level: lastT,
+			})
+
+		}
+	}
+	return taxLevels
+}
@@ -0,0 +1,172 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func thaiTaxRates() []TaxRate {
+	return []TaxRate{
+		{ID: 1, LowerBoundIncome: 0, TaxRate: 0},
+		{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+		{ID: 3, LowerBoundIncome: 500001 * Baht, TaxRate: 15 * Percent},
+		{ID: 4, LowerBoundIncome: 1000001 * Baht, TaxRate: 20 * Percent},
+		{ID: 5, LowerBoundIncome: 2000001 * Baht, TaxRate: 35 * Percent},
+	}
+}
+
+func TestProgressiveTax(t *testing.T) {
+	tests := []struct {
+		name          string
+		income        Money
+		expectedTotal Money
+		expectedBands []Money
+	}{
+		{
+			name:          "negative income",
+			income:        -10000 * Baht,
+			expectedTotal: 0,
+			expectedBands: []Money{0, 0, 0, 0, 0},
+		},
+		{
+			name:          "income inside exemption",
+			income:        150000 * Baht,
+			expectedTotal: 0,
+			expectedBands: []Money{0, 0, 0, 0, 0},
+		},
+		{
+			name:          "income in 10% bracket",
+			income:        440000 * Baht,
+			expectedTotal: 29000 * Baht,
+			expectedBands: []Money{0, 29000 * Baht, 0, 0, 0},
+		},
+		{
+			name:          "income in 15% bracket",
+			income:        750000 * Baht,
+			expectedTotal: 72500 * Baht,
+			expectedBands: []Money{0, 35000 * Baht, 37500 * Baht, 0, 0},
+		},
+		{
+			name:          "income in 35% bracket",
+			income:        2500000 * Baht,
+			expectedTotal: 485000 * Baht,
+			expectedBands: []Money{0, 35000 * Baht, 75000 * Baht, 200000 * Baht, 175000 * Baht},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			total, bands := progressiveTax(test.income, thaiTaxRates())
+			assert.Equal(t, test.expectedTotal, total)
+			assert.Equal(t, test.expectedBands, bands)
+		})
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	personal := TaxDeduction{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}
+	donation := TaxDeduction{TaxAllowanceType: "donation", MaxDeductionAmount: 100000 * Baht}
+
+	t.Run("fills every bracket", func(t *testing.T) {
+		res, err := Calculate(TaxCalculation{TotalIncome: 1060000 * Baht}, thaiTaxRates(), []TaxDeduction{personal})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 110000*Baht, res.Tax)
+		assert.Equal(t, Money(0), res.TaxRefund)
+		assert.Equal(t, 1000000*Baht, res.NetIncome)
+		assert.Equal(t, 60000*Baht, res.TotalDeductions)
+		assert.Equal(t, 110000*Baht, res.TaxBeforeWht)
+		assert.Equal(t, Rate(1037), res.EffectiveRate)
+		assert.Equal(t, 15*Percent, res.MarginalRate)
+		assert.Equal(t, []TaxLevelInfo{
+			{Level: "0-150,000", Tax: 0},
+			{Level: "150,001-500,000", Tax: 35000 * Baht},
+			{Level: "500,001-1,000,000", Tax: 75000 * Baht},
+			{Level: "1,000,001-2,000,000", Tax: 0},
+			{Level: "2,000,001 ขึ้นไป", Tax: 0},
+		}, res.TaxLevel)
+	})
+
+	t.Run("withholding tax larger than tax is refunded", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome:    500000 * Baht,
+			WithHoldingTax: 40000 * Baht,
+			Allowances:     []Allowance{{AllowanceType: "donation", Amount: 200000 * Baht}},
+		}
+		res, err := Calculate(tc, thaiTaxRates(), []TaxDeduction{personal, donation})
+
+		assert.NoError(t, err)
+		assert.Equal(t, Money(0), res.Tax)
+		assert.Equal(t, 21000*Baht, res.TaxRefund)
+		assert.Equal(t, 19000*Baht, res.TaxLevel[1].Tax)
+	})
+
+	t.Run("unsorted rates", func(t *testing.T) {
+		rates := thaiTaxRates()
+		rates[0], rates[4] = rates[4], rates[0]
+
+		res, err := Calculate(TaxCalculation{TotalIncome: 500000 * Baht}, rates, []TaxDeduction{personal})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 29000*Baht, res.Tax)
+		assert.Equal(t, "0-150,000", res.TaxLevel[0].Level)
+	})
+
+	t.Run("itemised incomes less expenses", func(t *testing.T) {
+		tc := TaxCalculation{
+			Incomes: []Income{
+				{IncomeType: IncomeSalary, Amount: 600000 * Baht},
+				{IncomeType: IncomeRent, Amount: 100000 * Baht, AssetType: "building"},
+			},
+		}
+		res, err := Calculate(tc, thaiTaxRates(), []TaxDeduction{personal})
+
+		// 700,000 - 100,000 - 30,000 - 60,000 = 510,000
+		assert.NoError(t, err)
+		assert.Equal(t, 36500*Baht, res.Tax)
+		assert.Equal(t, 190000*Baht, res.TotalDeductions)
+		assert.Equal(t, 15*Percent, res.MarginalRate)
+		assert.Equal(t, TaxMethodBracket, res.TaxMethod)
+		assert.Equal(t, []IncomeExpense{
+			{IncomeType: IncomeSalary, Section: "40(1)", Income: 600000 * Baht, Expense: 100000 * Baht},
+			{IncomeType: IncomeRent, Section: "40(5)", Income: 100000 * Baht, Expense: 30000 * Baht},
+		}, res.Expenses)
+	})
+
+	t.Run("minimum tax higher than bracket tax", func(t *testing.T) {
+		tc := TaxCalculation{
+			WithHoldingTax: 3000 * Baht,
+			Incomes: []Income{
+				{IncomeType: IncomeBusiness, Amount: 2000000 * Baht, ExpenseMethod: "actual", ActualExpense: 1900000 * Baht},
+			},
+		}
+		res, err := Calculate(tc, thaiTaxRates(), []TaxDeduction{personal})
+
+		assert.NoError(t, err)
+		assert.Equal(t, Money(0), res.BracketTax)
+		assert.Equal(t, 10000*Baht, res.MinimumTax)
+		assert.Equal(t, TaxMethodMinimum, res.TaxMethod)
+		assert.Equal(t, 7000*Baht, res.Tax)
+		assert.Equal(t, 10000*Baht, res.TaxBeforeWht)
+		assert.Equal(t, Rate(50), res.EffectiveRate)
+		assert.Equal(t, Rate(0), res.MarginalRate)
+	})
+
+	t.Run("total income differs from incomes", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome: 500000 * Baht,
+			Incomes:     []Income{{IncomeType: IncomeSalary, Amount: 600000 * Baht}},
+		}
+		_, err := Calculate(tc, thaiTaxRates(), []TaxDeduction{personal})
+
+		assert.Equal(t, errors.New("total income does not match the sum of incomes"), err)
+	})
+
+	t.Run("invalid withholding tax", func(t *testing.T) {
+		_, err := Calculate(TaxCalculation{TotalIncome: 500000 * Baht, WithHoldingTax: -1 * Baht}, thaiTaxRates(), nil)
+
+		assert.Equal(t, errors.New("invalid withholding tax amount"), err)
+	})
+}
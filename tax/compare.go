@@ -0,0 +1,67 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxScenarios bounds how many scenarios one comparison may carry.
+const maxScenarios = 10
+
+// Compare runs every scenario against the same snapshot and diffs each result
+// against the baseline, the first scenario unless one is named. Every
+// scenario is calculated for the snapshot's year.
+func Compare(cr ComparisonRequest, snap Snapshot) (ComparisonResponse, error) {
+	if len(cr.Scenarios) == 0 || len(cr.Scenarios) > maxScenarios {
+		return ComparisonResponse{}, fmt.Errorf("between 1 and %d scenarios are required", maxScenarios)
+	}
+
+	baseline := cr.Baseline
+	if baseline == "" {
+		baseline = cr.Scenarios[0].Name
+	}
+
+	results := make([]ScenarioResult, len(cr.Scenarios))
+	index := map[string]int{}
+	for i, s := range cr.Scenarios {
+		if _, ok := index[s.Name]; ok {
+			return ComparisonResponse{}, fmt.Errorf("scenario %s is named twice", s.Name)
+		}
+		index[s.Name] = i
+
+		tc := s.Calculation
+		tc.TaxYear = cr.TaxYear
+		res, err := Calculate(tc, snap.Rates, snap.Deductions)
+		if err != nil {
+			return ComparisonResponse{}, fmt.Errorf("scenario %s: %w", s.Name, err)
+		}
+		results[i] = ScenarioResult{Name: s.Name, Result: res}
+	}
+
+	b, ok := index[baseline]
+	if !ok {
+		return ComparisonResponse{}, errors.New("baseline scenario not found")
+	}
+	for i := range results {
+		results[i].Diff = scenarioDiff(results[b].Result, results[i].Result)
+	}
+
+	return ComparisonResponse{Baseline: baseline, Scenarios: results}, nil
+}
+
+// scenarioDiff is res less base, line by line. The brackets come from the
+// same rate table, but credit lines may only be in one of the two.
+func scenarioDiff(base, res CalculationResponse) ScenarioDiff {
+	d := ScenarioDiff{
+		Tax:       res.Tax - base.Tax,
+		TaxRefund: res.TaxRefund - base.TaxRefund,
+	}
+	baseTax := map[string]Money{}
+	for _, l := range base.TaxLevel {
+		baseTax[l.Level] = l.Tax
+	}
+	for _, l := range res.TaxLevel {
+		d.TaxLevel = append(d.TaxLevel, TaxLevelInfo{Level: l.Level, Tax: l.Tax - baseTax[l.Level]})
+	}
+	return d
+}
@@ -0,0 +1,78 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	snap := Snapshot{
+		Rates: thaiTaxRates(),
+		Deductions: []TaxDeduction{
+			{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht},
+			{TaxAllowanceType: "life-insurance", MaxDeductionAmount: 100000 * Baht},
+		},
+	}
+	current := Scenario{Name: "current", Calculation: TaxCalculation{TotalIncome: 500000 * Baht, WithHoldingTax: 25000 * Baht}}
+	insurance := Scenario{Name: "insurance", Calculation: TaxCalculation{
+		TotalIncome:    500000 * Baht,
+		WithHoldingTax: 25000 * Baht,
+		Allowances:     []Allowance{{AllowanceType: "life-insurance", Amount: 50000 * Baht}},
+	}}
+
+	t.Run("diff against the first scenario", func(t *testing.T) {
+		res, err := Compare(ComparisonRequest{Scenarios: []Scenario{current, insurance}}, snap)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "current", res.Baseline)
+		assert.Equal(t, 4000*Baht, res.Scenarios[0].Result.Tax)
+		assert.Equal(t, Money(0), res.Scenarios[0].Diff.Tax)
+		assert.Equal(t, Money(0), res.Scenarios[1].Result.Tax)
+		assert.Equal(t, 1000*Baht, res.Scenarios[1].Result.TaxRefund)
+		assert.Equal(t, ScenarioDiff{
+			Tax:       -4000 * Baht,
+			TaxRefund: 1000 * Baht,
+			TaxLevel: []TaxLevelInfo{
+				{Level: "0-150,000", Tax: 0},
+				{Level: "150,001-500,000", Tax: -5000 * Baht},
+				{Level: "500,001-1,000,000", Tax: 0},
+				{Level: "1,000,001-2,000,000", Tax: 0},
+				{Level: "2,000,001 ขึ้นไป", Tax: 0},
+			},
+		}, res.Scenarios[1].Diff)
+	})
+
+	t.Run("named baseline", func(t *testing.T) {
+		res, err := Compare(ComparisonRequest{Baseline: "insurance", Scenarios: []Scenario{current, insurance}}, snap)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4000*Baht, res.Scenarios[0].Diff.Tax)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			cr       ComparisonRequest
+			expected error
+		}{
+			{name: "unknown baseline", cr: ComparisonRequest{Baseline: "other", Scenarios: []Scenario{current}}, expected: errors.New("baseline scenario not found")},
+			{name: "same name twice", cr: ComparisonRequest{Scenarios: []Scenario{current, current}}, expected: errors.New("scenario current is named twice")},
+			{name: "no scenarios", cr: ComparisonRequest{}, expected: errors.New("between 1 and 10 scenarios are required")},
+			{
+				name:     "invalid scenario",
+				cr:       ComparisonRequest{Scenarios: []Scenario{{Name: "bad", Calculation: TaxCalculation{TotalIncome: 100 * Baht, WithHoldingTax: 200 * Baht}}}},
+				expected: errors.New("scenario bad: invalid withholding tax amount"),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, err := Compare(test.cr, snap)
+
+				assert.EqualError(t, err, test.expected.Error())
+			})
+		}
+	})
+}
@@ -0,0 +1,412 @@
+package tax
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Columns an upload may carry besides income and allowance types. employeeId
+// and name are only echoed back with the row's result; assetType is the kind
+// of asset behind the row's rent.
+const (
+	columnTotalIncome = "totalIncome"
+	columnWht         = "wht"
+	columnTaxYear     = "taxYear"
+	columnFilingType  = "filingType"
+	columnEmployeeID  = "employeeId"
+	columnName        = "name"
+	columnAssetType   = "assetType"
+)
+
+// csvSchema is how the header of an upload maps each column into a
+// TaxCalculation. A column that is neither a fixed column nor an income type
+// is taken as an allowance type and must be known to tax_deduction; any other
+// column fails the upload.
+type csvSchema struct {
+	header  []string
+	perUnit map[string]bool
+}
+
+func csvColumns(header []string) (csvSchema, error) {
+	seen := map[string]bool{}
+	hasIncome := false
+	for _, col := range header {
+		if seen[col] {
+			return csvSchema{}, fmt.Errorf("duplicate column %s", col)
+		}
+		seen[col] = true
+
+		_, income := incomeSections[col]
+		hasIncome = hasIncome || income || col == columnTotalIncome
+	}
+	if !hasIncome {
+		return csvSchema{}, errors.New("Invalid Header")
+	}
+	if seen[IncomeRent] && !seen[columnAssetType] {
+		return csvSchema{}, fmt.Errorf("%s column needs an %s column", IncomeRent, columnAssetType)
+	}
+	return csvSchema{header: header}, nil
+}
+
+// allowanceTypes lists the allowance columns, to be looked up in
+// tax_deduction.
+func (s csvSchema) allowanceTypes() []string {
+	var types []string
+	for _, col := range s.header {
+		if isAllowanceColumn(col) {
+			types = append(types, col)
+		}
+	}
+	return types
+}
+
+// known checks every allowance column against the deduction rows and notes
+// which of them are claimed as a number of people rather than an amount.
+func (s *csvSchema) known(tds []TaxDeduction) error {
+	rows := map[string]TaxDeduction{}
+	for _, td := range tds {
+		rows[td.TaxAllowanceType] = td
+	}
+
+	s.perUnit = map[string]bool{}
+	for _, col := range s.allowanceTypes() {
+		td, ok := rows[col]
+		if !ok {
+			return fmt.Errorf("unknown column %s", col)
+		}
+		s.perUnit[col] = td.PerUnit
+	}
+	return nil
+}
+
+// calculation maps one row into a TaxCalculation. The returned
+// TaxWithTotalIncome carries the row's employeeId and name even when the row
+// is rejected. Empty income and allowance cells are not claimed.
+func (s csvSchema) calculation(v []string) (TaxCalculation, TaxWithTotalIncome, error) {
+	tc := TaxCalculation{Allowances: []Allowance{}}
+	var tti TaxWithTotalIncome
+	var assetType string
+	for i, col := range s.header {
+		switch col {
+		case columnEmployeeID:
+			tti.EmployeeID = v[i]
+		case columnName:
+			tti.Name = v[i]
+		case columnAssetType:
+			assetType = v[i]
+		}
+	}
+
+	for i, col := range s.header {
+		cell := v[i]
+		switch {
+		case col == columnEmployeeID || col == columnName || col == columnAssetType:
+		case col == columnTotalIncome:
+			amount, err := ParseMoney(cell)
+			if err != nil {
+				return tc, tti, errors.New("total income can not be string or empty")
+			}
+			tc.TotalIncome = amount
+		case col == columnWht:
+			amount, err := ParseMoney(cell)
+			if err != nil {
+				return tc, tti, errors.New("tax with holding (twh) can not be string or empty")
+			}
+			tc.WithHoldingTax = amount
+		case col == columnTaxYear:
+			if cell == "" {
+				continue
+			}
+			year, err := strconv.Atoi(cell)
+			if err != nil {
+				return tc, tti, fmt.Errorf("invalid tax year %s", cell)
+			}
+			tc.TaxYear = year
+		case col == columnFilingType:
+			tc.FilingType = cell
+		case cell == "":
+		case s.perUnit[col]:
+			count, err := strconv.Atoi(cell)
+			if err != nil {
+				return tc, tti, fmt.Errorf("%s must be a number of people", col)
+			}
+			tc.Allowances = append(tc.Allowances, Allowance{AllowanceType: col, Count: count})
+		default:
+			amount, err := ParseMoney(cell)
+			if err != nil {
+				return tc, tti, fmt.Errorf("%s can not be string or empty", col)
+			}
+			if _, ok := incomeSections[col]; ok {
+				in := Income{IncomeType: col, Amount: amount}
+				if col == IncomeRent {
+					in.AssetType = assetType
+				}
+				tc.Incomes = append(tc.Incomes, in)
+				continue
+			}
+			tc.Allowances = append(tc.Allowances, Allowance{AllowanceType: col, Amount: amount})
+		}
+	}
+	return tc, tti, nil
+}
+
+func isAllowanceColumn(col string) bool {
+	switch col {
+	case columnTotalIncome, columnWht, columnTaxYear, columnFilingType, columnEmployeeID, columnName, columnAssetType:
+		return false
+	}
+	_, income := incomeSections[col]
+	return !income
+}
+
+// csvSheet collects the uploaded rows with the figures worked out for them,
+// for clients that download the result as a spreadsheet. A nil sheet keeps
+// nothing.
+type csvSheet struct {
+	header []string
+	rows   []csvSheetRow
+}
+
+type csvSheetRow struct {
+	cells []string
+	res   CalculationResponse
+	err   error
+}
+
+func (s *csvSheet) add(cells []string, res CalculationResponse, err error) {
+	if s == nil {
+		return
+	}
+	s.rows = append(s.rows, csvSheetRow{cells: cells, res: res, err: err})
+}
+
+// records is the uploaded header and rows followed by tax, taxRefund,
+// netIncome, the tax of every bracket and the error of rows that failed.
+func (s *csvSheet) records() [][]string {
+	var levels []string
+	seen := map[string]bool{}
+	for _, r := range s.rows {
+		for _, l := range r.res.TaxLevel {
+			if !seen[l.Level] {
+				seen[l.Level] = true
+				levels = append(levels, l.Level)
+			}
+		}
+	}
+
+	header := append(slices.Clone(s.header), "tax", "taxRefund", "netIncome")
+	for _, l := range levels {
+		header = append(header, "taxLevel "+l)
+	}
+	records := [][]string{append(header, "error")}
+
+	for _, r := range s.rows {
+		rec := make([]string, len(s.header), len(header)+1)
+		copy(rec, r.cells)
+		if r.err != nil {
+			rec = append(rec, make([]string, len(header)-len(s.header))...)
+			records = append(records, append(rec, r.err.Error()))
+			continue
+		}
+
+		rec = append(rec, r.res.Tax.String(), r.res.TaxRefund.String(), r.res.NetIncome.String())
+		for _, level := range levels {
+			var tax Money
+			for _, l := range r.res.TaxLevel {
+				if l.Level == level {
+					tax += l.Tax
+				}
+			}
+			rec = append(rec, tax.String())
+		}
+		records = append(records, append(rec, ""))
+	}
+	return records
+}
+
+// csvRow is one data row of an upload and what came of it. seq is its place
+// among the data rows and snap the rules of its tax year.
+type csvRow struct {
+	seq   int
+	line  int
+	cells []string
+	tc    TaxCalculation
+	tti   TaxWithTotalIncome
+	snap  Snapshot
+	res   CalculationResponse
+	err   error
+}
+
+// readCSVHeader reads the header row and maps its columns.
+func readCSVHeader(read *csv.Reader) ([]string, csvSchema, error) {
+	header, err := read.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, csvSchema{}, errors.New("Invalid Header")
+	}
+	if err != nil {
+		return nil, csvSchema{}, err
+	}
+
+	header = removeBOM(header)
+	schema, err := csvColumns(header)
+	return header, schema, err
+}
+
+// csvReadError is an upload that could not be read, as opposed to a store that
+// failed.
+type csvReadError struct{ error }
+
+// csvRows works out the data rows of an upload. Rows that cannot be read or
+// mapped keep their error; only a failing reader or store stops the upload.
+type csvRows struct {
+	schema   csvSchema
+	validate func(i interface{}) error
+	store    Storer
+	types    []string
+	taxYear  int
+	years    map[int]csvYear
+	workers  int
+}
+
+// csvYear is the snapshot of one tax year and, when the year has no row for
+// one of the allowance columns, the error its rows fail with.
+type csvYear struct {
+	snap Snapshot
+	err  error
+}
+
+// calculate streams the rows from read through a pool of workers and hands
+// each worked out row to emit in the order of the upload. The reader stays at
+// most a few rows per worker ahead of emit, so a large upload is never held
+// whole. Reading stops when ctx is done.
+func (c csvRows) calculate(ctx context.Context, read *csv.Reader, emit func(csvRow)) error {
+	workers := max(c.workers, 1)
+	window := make(chan struct{}, 4*workers)
+	next := make(chan csvRow)
+	out := make(chan csvRow)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range next {
+				if r.err == nil {
+					r.res, r.err = Calculate(r.tc, r.snap.Rates, r.snap.Deductions)
+				}
+				out <- r
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	errc := make(chan error, 1)
+	go func() {
+		defer close(next)
+		errc <- c.feed(ctx, read, window, next)
+	}()
+
+	pending := map[int]csvRow{}
+	seq := 0
+	for r := range out {
+		pending[r.seq] = r
+		for r, ok := pending[seq]; ok; r, ok = pending[seq] {
+			delete(pending, seq)
+			emit(r)
+			<-window
+			seq++
+		}
+	}
+	return <-errc
+}
+
+// feed reads and maps the rows one after the other, taking a place in window
+// for each, and reads the snapshot of every tax year once, on its first row.
+// Rows without a year are worked out for taxYear.
+func (c csvRows) feed(ctx context.Context, read *csv.Reader, window chan<- struct{}, next chan<- csvRow) error {
+	for seq := 0; ; seq++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		v, err := read.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		r := csvRow{seq: seq, cells: v}
+		var pe *csv.ParseError
+		switch {
+		case errors.As(err, &pe):
+			r.line, r.err = pe.StartLine, pe.Err
+		case err != nil:
+			return csvReadError{err}
+		default:
+			r.line, _ = read.FieldPos(0)
+			r.tc, r.tti, r.err = c.schema.calculation(v)
+			if r.err == nil {
+				r.err = c.validate(&r.tc)
+			}
+		}
+
+		if r.err == nil {
+			if r.tc.TaxYear == 0 {
+				r.tc.TaxYear = c.taxYear
+			}
+			year, err := c.year(r.tc.TaxYear)
+			if err != nil {
+				return err
+			}
+			r.snap, r.err = year.snap, year.err
+		}
+
+		window <- struct{}{}
+		next <- r
+	}
+}
+
+// year reads the snapshot of a tax year and checks the allowance columns
+// against it, so an allowance the year does not know fails its rows instead
+// of going unclaimed.
+func (c csvRows) year(taxYear int) (csvYear, error) {
+	if y, ok := c.years[taxYear]; ok {
+		return y, nil
+	}
+	snap, err := c.store.TaxSnapshot(taxYear, c.types)
+	if err != nil {
+		return csvYear{}, err
+	}
+
+	y := csvYear{snap: snap}
+	schema := c.schema
+	if err := schema.known(snap.Deductions); err != nil {
+		y.err = fmt.Errorf("%w in tax year %d", err, taxYear)
+	}
+	c.years[taxYear] = y
+	return y, nil
+}
+
+// add gathers a worked out row into the response and, when sheet is not nil,
+// the downloadable sheet.
+func (t *TaxCSVCalculation) add(r csvRow, sheet *csvSheet) {
+	sheet.add(r.cells, r.res, r.err)
+	if r.err != nil {
+		t.fail(r.line, r.tti.EmployeeID, r.err)
+		return
+	}
+
+	tti := r.tti
+	tti.Row = r.line
+	tti.TotalIncome = r.res.NetIncome + r.res.TotalDeductions
+	tti.TaxAmount = r.res.Tax
+	tti.TaxRefund = r.res.TaxRefund
+	t.Taxes = append(t.Taxes, tti)
+	t.Summary.RowsOK++
+}
@@ -0,0 +1,154 @@
+package tax
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVColumns(t *testing.T) {
+	tds := []TaxDeduction{
+		{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht},
+		{TaxAllowanceType: "k-receipt", MaxDeductionAmount: 50000 * Baht},
+		{TaxAllowanceType: "child", MaxDeductionAmount: 30000 * Baht, PerUnit: true},
+	}
+
+	t.Run("columns in any order", func(t *testing.T) {
+		schema, err := csvColumns([]string{"name", "child", "salary", "k-receipt", "employeeId", "wht", "taxYear"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"child", "k-receipt"}, schema.allowanceTypes())
+		assert.NoError(t, schema.known(tds))
+
+		tc, tti, err := schema.calculation([]string{"Somchai", "2", "600000", "", "E0001", "12000", "2566"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, TaxWithTotalIncome{EmployeeID: "E0001", Name: "Somchai"}, tti)
+		assert.Equal(t, TaxCalculation{
+			WithHoldingTax: 12000 * Baht,
+			TaxYear:        2566,
+			Incomes:        []Income{{IncomeType: IncomeSalary, Amount: 600000 * Baht}},
+			Allowances:     []Allowance{{AllowanceType: "child", Count: 2}},
+		}, tc)
+	})
+
+	t.Run("rent with its asset type", func(t *testing.T) {
+		schema, err := csvColumns([]string{"employeeId", "rent", "assetType"})
+		assert.NoError(t, err)
+		assert.Empty(t, schema.allowanceTypes())
+
+		tc, _, err := schema.calculation([]string{"E0003", "200000", "building"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Income{{IncomeType: IncomeRent, Amount: 200000 * Baht, AssetType: "building"}}, tc.Incomes)
+	})
+
+	t.Run("bad cell keeps the employee", func(t *testing.T) {
+		schema, _ := csvColumns([]string{"employeeId", "totalIncome", "child"})
+		schema.known(tds)
+
+		_, tti, err := schema.calculation([]string{"E0002", "500000", "two"})
+
+		assert.Equal(t, "E0002", tti.EmployeeID)
+		assert.Equal(t, errors.New("child must be a number of people"), err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := csvColumns([]string{"employeeId", "wht"})
+		assert.Equal(t, errors.New("Invalid Header"), err)
+
+		_, err = csvColumns([]string{"totalIncome", "wht", "wht"})
+		assert.Equal(t, errors.New("duplicate column wht"), err)
+
+		_, err = csvColumns([]string{"employeeId", "rent"})
+		assert.Equal(t, errors.New("rent column needs an assetType column"), err)
+
+		schema, _ := csvColumns([]string{"totalIncome", "lottery"})
+		assert.Equal(t, errors.New("unknown column lottery"), schema.known(tds))
+	})
+}
+
+func TestCSVRowsCalculate(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("totalIncome,taxYear\n")
+	for i := 0; i < 100; i++ {
+		year := ""
+		if i%2 == 1 {
+			year = "2566"
+		}
+		fmt.Fprintf(&b, "%d,%s\n", 210000+i*1000, year)
+	}
+	input := strings.Replace(b.String(), "213000", "abc", 1)
+
+	read := csv.NewReader(strings.NewReader(input))
+	_, schema, _ := readCSVHeader(read)
+	store := &countingStore{MockTax: MockTax{taxRates: thaiTaxRates(), taxDeductions: []TaxDeduction{{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}}}}
+	rows := csvRows{
+		schema:   schema,
+		validate: func(i interface{}) error { return nil },
+		store:    store,
+		taxYear:  2567,
+		years:    map[int]csvYear{},
+		workers:  4,
+	}
+
+	var got []csvRow
+	err := rows.calculate(context.Background(), read, func(r csvRow) { got = append(got, r) })
+
+	assert.NoError(t, err)
+	assert.Len(t, got, 100)
+	assert.Equal(t, int64(4), store.queries.Load())
+	for i, r := range got {
+		assert.Equal(t, i, r.seq)
+		assert.Equal(t, i+2, r.line)
+	}
+	assert.Equal(t, 0*Baht, got[0].res.Tax)
+	assert.Equal(t, 9900*Baht, got[99].res.Tax)
+	assert.Equal(t, 2566, got[1].tc.TaxYear)
+	assert.Equal(t, 2567, got[2].tc.TaxYear)
+	assert.EqualError(t, got[3].err, "total income can not be string or empty")
+}
+
+// yearStore keeps different deduction rows for each tax year.
+type yearStore struct {
+	MockTax
+	deductions map[int][]TaxDeduction
+}
+
+func (s yearStore) TaxSnapshot(taxYear int, allowanceTypes []string) (Snapshot, error) {
+	return Snapshot{Rates: s.taxRates, Deductions: s.deductions[taxYear]}, nil
+}
+
+func TestCSVRowsUnknownInYear(t *testing.T) {
+	personal := TaxDeduction{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}
+	esg := TaxDeduction{TaxAllowanceType: "thai-esg", MaxDeductionAmount: 300000 * Baht}
+	store := yearStore{
+		MockTax:    MockTax{taxRates: thaiTaxRates()},
+		deductions: map[int][]TaxDeduction{2567: {personal, esg}, 2566: {personal}},
+	}
+
+	read := csv.NewReader(strings.NewReader("totalIncome,taxYear,thai-esg\n500000,,10000\n500000,2566,10000\n"))
+	_, schema, _ := readCSVHeader(read)
+	snap, _ := store.TaxSnapshot(2567, nil)
+	assert.NoError(t, schema.known(snap.Deductions))
+	rows := csvRows{
+		schema:   schema,
+		validate: func(i interface{}) error { return nil },
+		store:    store,
+		taxYear:  2567,
+		years:    map[int]csvYear{2567: {snap: snap}},
+		workers:  2,
+	}
+
+	var got []csvRow
+	err := rows.calculate(context.Background(), read, func(r csvRow) { got = append(got, r) })
+
+	assert.NoError(t, err)
+	assert.NoError(t, got[0].err)
+	assert.Equal(t, 70000*Baht, got[0].res.TotalDeductions)
+	assert.EqualError(t, got[1].err, "unknown column thai-esg in tax year 2566")
+}
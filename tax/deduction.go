@@ -0,0 +1,171 @@
+package tax
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Rules reported in DeductionDetail.ClampedBy.
+const (
+	ruleMaxDeductionAmount      = "max_deduction_amount"
+	ruleMaxPercentOfIncome      = "max_percent_of_income"
+	ruleMaxUnits                = "max_units"
+	ruleGroupMaxAmount          = "group_max_amount"
+	ruleGroupMaxPercentOfIncome = "group_max_percent_of_income"
+)
+
+// deductAllowances works out how much of each claimed allowance is allowed.
+// Every allowance is first limited by its own row, then allowances sharing a
+// deduction group are limited together by the group ceiling in claim order.
+// Percentage caps are taken from assessable income. Rows marked
+// AfterDeductions, the donations, come last and take their percentage from
+// the net income left after expenses and everything before them.
+func deductAllowances(tds []TaxDeduction, alls []Allowance, income Money, net Money) []DeductionDetail {
+	rows := map[string]TaxDeduction{}
+	for _, td := range tds {
+		rows[td.TaxAllowanceType] = td
+	}
+
+	var details []DeductionDetail
+	if td, ok := rows["personal"]; ok {
+		details = append(details, DeductionDetail{
+			AllowanceType: td.TaxAllowanceType,
+			Requested:     td.MaxDeductionAmount,
+			Allowed:       td.MaxDeductionAmount,
+			Cap:           td.MaxDeductionAmount,
+			CapRule:       ruleMaxDeductionAmount,
+		})
+	}
+
+	var afterDeductions []Allowance
+	for _, a := range mergeAllowances(alls) {
+		td, ok := rows[a.AllowanceType]
+		if !ok || a.AllowanceType == "personal" {
+			continue
+		}
+		if td.AfterDeductions {
+			afterDeductions = append(afterDeductions, a)
+			continue
+		}
+		details = append(details, allowanceCap(td, a, income))
+	}
+
+	groupUsed := map[string]Money{}
+	for i, d := range details {
+		td := rows[d.AllowanceType]
+		ceiling, rule, ok := groupCeiling(td, income)
+		if !ok {
+			continue
+		}
+
+		remaining := max(ceiling-groupUsed[td.DeductionGroup], 0)
+		if remaining < d.Cap {
+			details[i].Cap = remaining
+			details[i].CapRule = rule
+		}
+		if d.Allowed > remaining {
+			details[i].Allowed = remaining
+			details[i].Clamped = d.Requested - remaining
+			details[i].ClampedBy = rule
+		}
+		details[i].Group = td.DeductionGroup
+		groupUsed[td.DeductionGroup] += details[i].Allowed
+	}
+
+	remaining := net
+	for _, d := range details {
+		remaining -= d.Allowed
+	}
+
+	// Donations counted more than once (education, sports, hospitals) are
+	// deducted before ordinary donations, as on the PND 90/91 form.
+	slices.SortStableFunc(afterDeductions, func(a, b Allowance) int {
+		return cmp.Compare(rows[b.AllowanceType].DeductionRate, rows[a.AllowanceType].DeductionRate)
+	})
+	for _, a := range afterDeductions {
+		d := allowanceCap(rows[a.AllowanceType], a, max(remaining, 0))
+		remaining -= d.Allowed
+		details = append(details, d)
+	}
+
+	return details
+}
+
+// allowanceCap applies the row's own rule: a fixed amount per person for
+// per-unit allowances such as children, otherwise the claimed amount, scaled
+// by DeductionRate, limited by the fixed ceiling and, when set, a percentage
+// of income. A zero fixed ceiling on a percentage rule means no fixed ceiling.
+// The detail carries the lowest of these limits whether or not it was reached.
+func allowanceCap(td TaxDeduction, a Allowance, income Money) DeductionDetail {
+	d := DeductionDetail{AllowanceType: a.AllowanceType}
+
+	if td.PerUnit {
+		units := max(a.Count, 1)
+		d.Requested = td.MaxDeductionAmount * Money(units)
+		d.Cap, d.CapRule = d.Requested, ruleMaxDeductionAmount
+		if td.MaxUnits > 0 && units >= td.MaxUnits {
+			d.Cap, d.CapRule = td.MaxDeductionAmount*Money(td.MaxUnits), ruleMaxUnits
+		}
+		d.Allowed = min(d.Requested, d.Cap)
+		if d.Allowed < d.Requested {
+			d.ClampedBy = d.CapRule
+		}
+		d.Clamped = d.Requested - d.Allowed
+		return d
+	}
+
+	d.Requested = a.Amount
+	if td.DeductionRate > 0 {
+		d.Requested = a.Amount.MulRate(td.DeductionRate)
+	}
+	hasFixedCap := td.MaxDeductionAmount > 0 || td.MaxPercentOfIncome == 0
+	if hasFixedCap {
+		d.Cap, d.CapRule = td.MaxDeductionAmount, ruleMaxDeductionAmount
+	}
+	if td.MaxPercentOfIncome > 0 {
+		if limit := income.MulRate(td.MaxPercentOfIncome); !hasFixedCap || limit < d.Cap {
+			d.Cap, d.CapRule = limit, ruleMaxPercentOfIncome
+		}
+	}
+	d.Allowed = min(d.Requested, d.Cap)
+	if d.Allowed < d.Requested {
+		d.ClampedBy = d.CapRule
+	}
+	d.Clamped = d.Requested - d.Allowed
+	return d
+}
+
+// groupCeiling is the combined limit of the row's group, the lower of the fixed
+// amount and the percentage of income when the group has one.
+func groupCeiling(td TaxDeduction, income Money) (Money, string, bool) {
+	if td.DeductionGroup == "" || (td.GroupMaxAmount == 0 && td.GroupMaxPercentOfIncome == 0) {
+		return 0, "", false
+	}
+
+	if td.GroupMaxPercentOfIncome > 0 {
+		limit := income.MulRate(td.GroupMaxPercentOfIncome)
+		if td.GroupMaxAmount == 0 || limit < td.GroupMaxAmount {
+			return limit, ruleGroupMaxPercentOfIncome, true
+		}
+	}
+	return td.GroupMaxAmount, ruleGroupMaxAmount, true
+}
+
+// mergeAllowances adds up repeated claims of the same type so each type is
+// capped once, keeping the order of first appearance.
+func mergeAllowances(alls []Allowance) []Allowance {
+	var merged []Allowance
+	index := map[string]int{}
+	for _, a := range alls {
+		a.Count = max(a.Count, 1)
+		i, ok := index[a.AllowanceType]
+		if !ok {
+			index[a.AllowanceType] = len(merged)
+			merged = append(merged, a)
+			continue
+		}
+		merged[i].Amount += a.Amount
+		merged[i].Count += a.Count
+	}
+	return merged
+}
@@ -0,0 +1,124 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeductAllowances(t *testing.T) {
+	retirement := func(allowanceType string, maxAmount Money, percent Rate) TaxDeduction {
+		return TaxDeduction{
+			TaxAllowanceType:   allowanceType,
+			MaxDeductionAmount: maxAmount,
+			MaxPercentOfIncome: percent,
+			DeductionGroup:     "retirement",
+			GroupMaxAmount:     500000 * Baht,
+		}
+	}
+
+	t.Run("own cap before group ceiling", func(t *testing.T) {
+		tds := []TaxDeduction{
+			{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht},
+			retirement("provident-fund", 500000*Baht, 15*Percent),
+			retirement("rmf", 500000*Baht, 30*Percent),
+			retirement("pension-insurance", 200000*Baht, 15*Percent),
+		}
+		alls := []Allowance{
+			{AllowanceType: "provident-fund", Amount: 400000 * Baht},
+			{AllowanceType: "rmf", Amount: 300000 * Baht},
+			{AllowanceType: "pension-insurance", Amount: 200000 * Baht},
+		}
+
+		got := deductAllowances(tds, alls, 2000000*Baht, 2000000*Baht)
+
+		assert.Equal(t, []DeductionDetail{
+			{AllowanceType: "personal", Requested: 60000 * Baht, Allowed: 60000 * Baht, Cap: 60000 * Baht, CapRule: ruleMaxDeductionAmount},
+			{AllowanceType: "provident-fund", Requested: 400000 * Baht, Allowed: 300000 * Baht, Clamped: 100000 * Baht, Cap: 300000 * Baht, CapRule: ruleMaxPercentOfIncome, ClampedBy: ruleMaxPercentOfIncome, Group: "retirement"},
+			{AllowanceType: "rmf", Requested: 300000 * Baht, Allowed: 200000 * Baht, Clamped: 100000 * Baht, Cap: 200000 * Baht, CapRule: ruleGroupMaxAmount, ClampedBy: ruleGroupMaxAmount, Group: "retirement"},
+			{AllowanceType: "pension-insurance", Requested: 200000 * Baht, Allowed: 0, Clamped: 200000 * Baht, CapRule: ruleGroupMaxAmount, ClampedBy: ruleGroupMaxAmount, Group: "retirement"},
+		}, got)
+	})
+
+	t.Run("group percent of income", func(t *testing.T) {
+		tds := []TaxDeduction{
+			{TaxAllowanceType: "life-insurance", MaxDeductionAmount: 100000 * Baht, DeductionGroup: "insurance", GroupMaxAmount: 100000 * Baht, GroupMaxPercentOfIncome: 10 * Percent},
+			{TaxAllowanceType: "health-insurance", MaxDeductionAmount: 25000 * Baht, DeductionGroup: "insurance", GroupMaxAmount: 100000 * Baht, GroupMaxPercentOfIncome: 10 * Percent},
+		}
+		alls := []Allowance{
+			{AllowanceType: "life-insurance", Amount: 30000 * Baht},
+			{AllowanceType: "health-insurance", Amount: 30000 * Baht},
+		}
+
+		got := deductAllowances(tds, alls, 500000*Baht, 500000*Baht)
+
+		assert.Equal(t, 30000*Baht, got[0].Allowed)
+		assert.Equal(t, 20000*Baht, got[1].Allowed)
+		assert.Equal(t, 10000*Baht, got[1].Clamped)
+		assert.Equal(t, ruleGroupMaxPercentOfIncome, got[1].ClampedBy)
+	})
+
+	t.Run("repeated claims are capped once", func(t *testing.T) {
+		tds := []TaxDeduction{
+			{TaxAllowanceType: "donation", MaxDeductionAmount: 100000 * Baht},
+			{TaxAllowanceType: "parent", MaxDeductionAmount: 30000 * Baht, PerUnit: true, MaxUnits: 4},
+		}
+		alls := []Allowance{
+			{AllowanceType: "donation", Amount: 80000 * Baht},
+			{AllowanceType: "parent", Count: 3},
+			{AllowanceType: "donation", Amount: 80000 * Baht},
+			{AllowanceType: "parent"},
+			{AllowanceType: "parent"},
+		}
+
+		got := deductAllowances(tds, alls, 500000*Baht, 500000*Baht)
+
+		assert.Equal(t, []DeductionDetail{
+			{AllowanceType: "donation", Requested: 160000 * Baht, Allowed: 100000 * Baht, Clamped: 60000 * Baht, Cap: 100000 * Baht, CapRule: ruleMaxDeductionAmount, ClampedBy: ruleMaxDeductionAmount},
+			{AllowanceType: "parent", Requested: 150000 * Baht, Allowed: 120000 * Baht, Clamped: 30000 * Baht, Cap: 120000 * Baht, CapRule: ruleMaxUnits, ClampedBy: ruleMaxUnits},
+		}, got)
+	})
+
+	t.Run("donations limited by income after other deductions", func(t *testing.T) {
+		tds := []TaxDeduction{
+			{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht},
+			{TaxAllowanceType: "donation", MaxPercentOfIncome: 10 * Percent, AfterDeductions: true, DeductionRate: 100 * Percent},
+			{TaxAllowanceType: "donation-education", MaxPercentOfIncome: 10 * Percent, AfterDeductions: true, DeductionRate: 200 * Percent},
+			{TaxAllowanceType: "k-receipt", MaxDeductionAmount: 50000 * Baht},
+		}
+		alls := []Allowance{
+			{AllowanceType: "donation", Amount: 100000 * Baht},
+			{AllowanceType: "donation-education", Amount: 10000 * Baht},
+			{AllowanceType: "k-receipt", Amount: 40000 * Baht},
+		}
+
+		got := deductAllowances(tds, alls, 500000*Baht, 500000*Baht)
+
+		// 500,000 - 60,000 - 40,000 = 400,000; education counts 20,000 of its
+		// 40,000 cap, leaving 10% of 380,000 for ordinary donations.
+		assert.Equal(t, []DeductionDetail{
+			{AllowanceType: "personal", Requested: 60000 * Baht, Allowed: 60000 * Baht, Cap: 60000 * Baht, CapRule: ruleMaxDeductionAmount},
+			{AllowanceType: "k-receipt", Requested: 40000 * Baht, Allowed: 40000 * Baht, Cap: 50000 * Baht, CapRule: ruleMaxDeductionAmount},
+			{AllowanceType: "donation-education", Requested: 20000 * Baht, Allowed: 20000 * Baht, Cap: 40000 * Baht, CapRule: ruleMaxPercentOfIncome},
+			{AllowanceType: "donation", Requested: 100000 * Baht, Allowed: 38000 * Baht, Clamped: 62000 * Baht, Cap: 38000 * Baht, CapRule: ruleMaxPercentOfIncome, ClampedBy: ruleMaxPercentOfIncome},
+		}, got)
+	})
+
+	t.Run("education donation doubled up to the cap", func(t *testing.T) {
+		tds := []TaxDeduction{
+			{TaxAllowanceType: "donation-education", MaxPercentOfIncome: 10 * Percent, AfterDeductions: true, DeductionRate: 200 * Percent},
+		}
+		alls := []Allowance{{AllowanceType: "donation-education", Amount: 30000 * Baht}}
+
+		got := deductAllowances(tds, alls, 400000*Baht, 400000*Baht)
+
+		assert.Equal(t, 60000*Baht, got[0].Requested)
+		assert.Equal(t, 40000*Baht, got[0].Allowed)
+	})
+
+	t.Run("unknown allowance type is ignored", func(t *testing.T) {
+		got := deductAllowances(nil, []Allowance{{AllowanceType: "lottery", Amount: 100 * Baht}}, 500000*Baht, 500000*Baht)
+
+		assert.Empty(t, got)
+	})
+}
@@ -0,0 +1,74 @@
+package tax
+
+import "errors"
+
+// Dividend elections reported in DividendComparison.Recommended.
+const (
+	DividendIncluded         = "included"
+	DividendFinalWithholding = "final-withholding"
+)
+
+// dividendCredit is the corporate tax already paid on the profit behind a
+// dividend, amount x rate / (100 - rate), under Section 47 bis.
+func dividendCredit(d Dividend) Money {
+	return d.Amount.MulDiv(int64(d.CorporateTaxRate), int64(100*Percent-d.CorporateTaxRate))
+}
+
+// includedDividends is what including the dividends adds to assessable
+// income, the dividends grossed up by their credit, and what it credits
+// against the tax, the credit and the 10% withheld.
+func includedDividends(ds []Dividend) (Money, Money) {
+	var income, credits Money
+	for _, d := range ds {
+		credit := dividendCredit(d)
+		income += d.Amount + credit
+		credits += d.Withheld + credit
+	}
+	return income, credits
+}
+
+func validationDividends(ds []Dividend) error {
+	for _, d := range ds {
+		if d.Amount < 0 || d.Withheld < 0 || d.Withheld > d.Amount {
+			return errors.New("invalid dividend amount")
+		}
+		if d.CorporateTaxRate < 0 || d.CorporateTaxRate >= 100*Percent {
+			return errors.New("invalid corporate tax rate")
+		}
+	}
+	return nil
+}
+
+// compareDividends works out both elections. Left as final withholding, the
+// dividends stay out of the return and the 10% withheld is the end of it.
+// The better election is the one that leaves less to pay after credits.
+func compareDividends(tc TaxCalculation, rates []TaxRate, tds []TaxDeduction) (*DividendComparison, error) {
+	tc.IncludeDividends = true
+	included, err := calculate(tc, rates, tds)
+	if err != nil {
+		return nil, err
+	}
+
+	tc.IncludeDividends = false
+	final, err := calculate(tc, rates, tds)
+	if err != nil {
+		return nil, err
+	}
+
+	_, credits := includedDividends(tc.Dividends)
+	var withheld Money
+	for _, d := range tc.Dividends {
+		withheld += d.Withheld
+	}
+
+	dc := &DividendComparison{
+		Included:         FilingOption{Tax: included.Tax, TaxRefund: included.TaxRefund, TaxBeforeWht: included.TaxBeforeWht},
+		FinalWithholding: FilingOption{Tax: final.Tax, TaxRefund: final.TaxRefund, TaxBeforeWht: final.TaxBeforeWht},
+		Credit:           credits - withheld,
+		Recommended:      DividendFinalWithholding,
+	}
+	if included.Tax-included.TaxRefund < final.Tax-final.TaxRefund {
+		dc.Recommended = DividendIncluded
+	}
+	return dc, nil
+}
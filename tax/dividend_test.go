@@ -0,0 +1,54 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDividends(t *testing.T) {
+	tds := []TaxDeduction{{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}}
+	dividend := Dividend{Amount: 90000 * Baht, Withheld: 9000 * Baht, CorporateTaxRate: 20 * Percent}
+
+	t.Run("credit", func(t *testing.T) {
+		assert.Equal(t, 22500*Baht, dividendCredit(dividend))
+		assert.Equal(t, 30000*Baht, dividendCredit(Dividend{Amount: 70000 * Baht, CorporateTaxRate: 30 * Percent}))
+	})
+
+	t.Run("including is better in a low bracket", func(t *testing.T) {
+		tc := TaxCalculation{TotalIncome: 300000 * Baht, Dividends: []Dividend{dividend}, IncludeDividends: true}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		// 300,000 + 112,500 - 60,000 = 352,500 is taxed 20,250, less the
+		// 22,500 credit and 9,000 withheld.
+		assert.NoError(t, err)
+		assert.Equal(t, 20250*Baht, res.TaxBeforeWht)
+		assert.Equal(t, 11250*Baht, res.TaxRefund)
+		assert.Equal(t, &DividendComparison{
+			Included:         FilingOption{TaxRefund: 11250 * Baht, TaxBeforeWht: 20250 * Baht},
+			FinalWithholding: FilingOption{Tax: 9000 * Baht, TaxBeforeWht: 9000 * Baht},
+			Credit:           22500 * Baht,
+			Recommended:      DividendIncluded,
+		}, res.Dividends)
+	})
+
+	t.Run("final withholding is better in the top bracket", func(t *testing.T) {
+		tc := TaxCalculation{TotalIncome: 5000000 * Baht, Dividends: []Dividend{dividend}}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, res.Dividends.FinalWithholding.Tax, res.Tax)
+		assert.Equal(t, DividendFinalWithholding, res.Dividends.Recommended)
+	})
+
+	t.Run("invalid corporate tax rate", func(t *testing.T) {
+		tc := TaxCalculation{TotalIncome: 300000 * Baht, Dividends: []Dividend{{Amount: 1000 * Baht, CorporateTaxRate: 100 * Percent}}}
+
+		_, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.Equal(t, errors.New("invalid corporate tax rate"), err)
+	})
+}
@@ -0,0 +1,133 @@
+package tax
+
+// Steps reported in ExplanationStep.Step, in the order they are listed.
+const (
+	stepGrossIncome      = "gross_income"
+	stepDividend         = "dividend"
+	stepForeignIncome    = "foreign_income"
+	stepExpense          = "expense"
+	stepAllowance        = "allowance"
+	stepNetIncome        = "net_income"
+	stepBracket          = "bracket"
+	stepBracketTax       = "bracket_tax"
+	stepMinimumTax       = "minimum_tax"
+	stepTaxDue           = "tax_due"
+	stepWht              = "wht"
+	stepHalfYearTax      = "half_year_tax"
+	stepForeignTaxCredit = "foreign_tax_credit"
+	stepDividendCredit   = "dividend_credit"
+	stepTaxPayable       = "tax_payable"
+	stepTaxRefund        = "tax_refund"
+	stepSurcharge        = "surcharge"
+	stepPenalty          = "penalty"
+	stepTotalDue         = "total_due"
+)
+
+// explanation lists how res was worked out from tc, one step per figure, so
+// it can be shown as is. Calculate does not build it; the handler asks for it
+// only when the client does.
+func explanation(tc TaxCalculation, rates []TaxRate, res CalculationResponse) []ExplanationStep {
+	gross := tc.TotalIncome
+	if len(tc.Incomes) > 0 {
+		gross = 0
+		for _, in := range tc.Incomes {
+			gross += in.Amount
+		}
+	}
+	rates = sortedRates(rates)
+
+	steps := []ExplanationStep{
+		{Step: stepGrossIncome, LabelTH: "เงินได้พึงประเมิน", LabelEN: "Gross income", Amount: gross},
+	}
+
+	if tc.IncludeDividends {
+		dividends, _ := includedDividends(tc.Dividends)
+		steps = append(steps, ExplanationStep{Step: stepDividend, LabelTH: "เงินปันผลรวมเครดิตภาษี", LabelEN: "Dividends with tax credit", Amount: dividends})
+	}
+	for _, f := range tc.ForeignIncomes {
+		steps = append(steps, ExplanationStep{Step: stepForeignIncome, LabelTH: "เงินได้จากต่างประเทศ " + f.Country, LabelEN: "Foreign income " + f.Country, Amount: f.Amount})
+	}
+
+	for _, e := range res.Expenses {
+		steps = append(steps, ExplanationStep{
+			Step:      stepExpense,
+			LabelTH:   "หักค่าใช้จ่าย " + e.Section,
+			LabelEN:   "Expense " + e.Section,
+			Amount:    e.Expense,
+			Requested: e.Income,
+		})
+	}
+
+	for _, d := range res.Deductions {
+		steps = append(steps, ExplanationStep{
+			Step:      stepAllowance,
+			LabelTH:   "ค่าลดหย่อน " + d.AllowanceType,
+			LabelEN:   "Allowance " + d.AllowanceType,
+			Amount:    d.Allowed,
+			Requested: d.Requested,
+			Cap:       d.Cap,
+			CapRule:   d.CapRule,
+			ClampedBy: d.ClampedBy,
+		})
+	}
+
+	steps = append(steps, ExplanationStep{Step: stepNetIncome, LabelTH: "เงินได้สุทธิ", LabelEN: "Net income", Amount: res.NetIncome})
+
+	for i, r := range rates {
+		l := res.TaxLevel[i]
+		steps = append(steps, ExplanationStep{
+			Step:    stepBracket,
+			LabelTH: "ภาษีขั้น " + l.Level,
+			LabelEN: "Tax for " + l.Level,
+			Amount:  l.Tax,
+			Rate:    r.TaxRate,
+		})
+	}
+
+	steps = append(steps, ExplanationStep{Step: stepBracketTax, LabelTH: "ภาษีตามขั้นบันได", LabelEN: "Tax by brackets", Amount: res.BracketTax})
+	if res.MinimumTax > 0 {
+		steps = append(steps, ExplanationStep{Step: stepMinimumTax, LabelTH: "ภาษีวิธีที่ 2 (0.5% ของเงินได้)", LabelEN: "Minimum tax (0.5% of income)", Amount: res.MinimumTax})
+	}
+
+	steps = append(steps,
+		ExplanationStep{Step: stepTaxDue, LabelTH: "ภาษีที่ต้องเสีย", LabelEN: "Tax due", Amount: res.TaxBeforeWht},
+		ExplanationStep{Step: stepWht, LabelTH: "หักภาษี ณ ที่จ่าย", LabelEN: "Withholding tax", Amount: prepaidFrom(res, PrepaymentWht)},
+	)
+	if paid := prepaidFrom(res, PrepaymentHalfYear); paid > 0 {
+		steps = append(steps, ExplanationStep{Step: stepHalfYearTax, LabelTH: "ภาษีที่ชำระแล้วตาม ภ.ง.ด.94", LabelEN: "Half-year tax paid", Amount: paid})
+	}
+	for _, f := range res.ForeignTaxCredits {
+		steps = append(steps, ExplanationStep{
+			Step:      stepForeignTaxCredit,
+			LabelTH:   "เครดิตภาษีต่างประเทศ " + f.Country,
+			LabelEN:   "Foreign tax credit " + f.Country,
+			Amount:    f.Credit,
+			Requested: f.ForeignTaxPaid,
+		})
+	}
+	if credit := prepaidFrom(res, PrepaymentDividend); credit > 0 {
+		steps = append(steps, ExplanationStep{Step: stepDividendCredit, LabelTH: "เครดิตภาษีเงินปันผลและภาษีที่ถูกหัก", LabelEN: "Dividend tax credit and tax withheld", Amount: credit})
+	}
+
+	steps = append(steps,
+		ExplanationStep{Step: stepTaxPayable, LabelTH: "ภาษีที่ต้องชำระเพิ่ม", LabelEN: "Tax payable", Amount: res.Tax},
+		ExplanationStep{Step: stepTaxRefund, LabelTH: "ภาษีที่ได้รับคืน", LabelEN: "Tax refund", Amount: res.TaxRefund},
+	)
+	if lc := res.LateCharges; lc != nil {
+		steps = append(steps,
+			ExplanationStep{Step: stepSurcharge, LabelTH: "เงินเพิ่มร้อยละ 1.5 ต่อเดือน", LabelEN: "Surcharge at 1.5% a month", Amount: lc.Surcharge, Rate: surchargeRate * Rate(lc.MonthsLate)},
+			ExplanationStep{Step: stepPenalty, LabelTH: "เบี้ยปรับ", LabelEN: "Penalty", Amount: lc.Penalty},
+			ExplanationStep{Step: stepTotalDue, LabelTH: "รวมที่ต้องชำระ", LabelEN: "Total due", Amount: lc.TotalDue},
+		)
+	}
+	return steps
+}
+
+func prepaidFrom(res CalculationResponse, source string) Money {
+	for _, p := range res.Prepayments {
+		if p.Source == source {
+			return p.Amount
+		}
+	}
+	return 0
+}
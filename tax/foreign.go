@@ -0,0 +1,53 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+)
+
+// foreignIncomeFrom is the first tax year in which foreign income remitted to
+// Thailand is assessable whenever it was earned.
+const foreignIncomeFrom = 2567
+
+func validationForeignIncomes(tc TaxCalculation) error {
+	if len(tc.ForeignIncomes) > 0 && tc.TaxYear != 0 && tc.TaxYear < foreignIncomeFrom {
+		return fmt.Errorf("foreign income is assessable from tax year %d", foreignIncomeFrom)
+	}
+	for _, f := range tc.ForeignIncomes {
+		if f.Country == "" {
+			return errors.New("country of foreign income is required")
+		}
+		if f.Amount < 0 || f.ForeignTaxPaid < 0 {
+			return fmt.Errorf("amount for foreign income from %s can not be negative", f.Country)
+		}
+	}
+	return nil
+}
+
+// foreignTaxCredits credits the tax paid abroad on each foreign income, up
+// to the share of the Thai tax that income bears, one line per country. The
+// credits together never go past tax, so they are never refunded; prepayments
+// are taken after them and still are.
+func foreignTaxCredits(fs []ForeignIncome, tax, assessable Money) []ForeignTaxCredit {
+	left := tax
+	var credits []ForeignTaxCredit
+	index := map[string]int{}
+	for _, f := range fs {
+		var attributable Money
+		if assessable > 0 {
+			attributable = tax.MulDiv(int64(f.Amount), int64(assessable))
+		}
+
+		i, ok := index[f.Country]
+		if !ok {
+			index[f.Country] = len(credits)
+			credits = append(credits, ForeignTaxCredit{Country: f.Country})
+			i = len(credits) - 1
+		}
+		credit := min(f.ForeignTaxPaid, attributable, left)
+		left -= credit
+		credits[i].ForeignTaxPaid += f.ForeignTaxPaid
+		credits[i].Credit += credit
+	}
+	return credits
+}
@@ -0,0 +1,120 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForeignIncome(t *testing.T) {
+	tds := []TaxDeduction{{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}}
+
+	t.Run("credit limited to the thai tax on the income", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome: 1000000 * Baht,
+			ForeignIncomes: []ForeignIncome{
+				{Country: "US", Amount: 400000 * Baht, ForeignTaxPaid: 100000 * Baht},
+				{Country: "JP", Amount: 100000 * Baht, ForeignTaxPaid: 10000 * Baht},
+			},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		// 1,440,000 net is taxed 198,000; the US income bears 4/15 of it.
+		assert.NoError(t, err)
+		assert.Equal(t, 198000*Baht, res.TaxBeforeWht)
+		assert.Equal(t, []ForeignTaxCredit{
+			{Country: "US", ForeignTaxPaid: 100000 * Baht, Credit: 52800 * Baht},
+			{Country: "JP", ForeignTaxPaid: 10000 * Baht, Credit: 10000 * Baht},
+		}, res.ForeignTaxCredits)
+		assert.Equal(t, 135200*Baht, res.Tax)
+		assert.Equal(t, []TaxLevelInfo{
+			{Level: "เครดิตภาษีต่างประเทศ US", Tax: -52800 * Baht},
+			{Level: "เครดิตภาษีต่างประเทศ JP", Tax: -10000 * Baht},
+		}, res.TaxLevel[5:])
+		assert.Contains(t, explanation(tc, thaiTaxRates(), res), ExplanationStep{
+			Step:      "foreign_tax_credit",
+			LabelTH:   "เครดิตภาษีต่างประเทศ US",
+			LabelEN:   "Foreign tax credit US",
+			Amount:    52800 * Baht,
+			Requested: 100000 * Baht,
+		})
+	})
+
+	t.Run("withholding is refunded after the credit", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome:    1000000 * Baht,
+			WithHoldingTax: 200000 * Baht,
+			ForeignIncomes: []ForeignIncome{{Country: "US", Amount: 500000 * Baht, ForeignTaxPaid: 100000 * Baht}},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 198000*Baht, res.TaxBeforeWht)
+		assert.Equal(t, Money(0), res.Tax)
+		assert.Equal(t, 68000*Baht, res.TaxRefund)
+		assert.Equal(t, []ForeignTaxCredit{{Country: "US", ForeignTaxPaid: 100000 * Baht, Credit: 66000 * Baht}}, res.ForeignTaxCredits)
+	})
+
+	t.Run("credit comes off the tax before withholding", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome:    1000000 * Baht,
+			WithHoldingTax: 150000 * Baht,
+			ForeignIncomes: []ForeignIncome{{Country: "US", Amount: 500000 * Baht, ForeignTaxPaid: 100000 * Baht}},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, Money(0), res.Tax)
+		assert.Equal(t, 18000*Baht, res.TaxRefund)
+		assert.Equal(t, []ForeignTaxCredit{{Country: "US", ForeignTaxPaid: 100000 * Baht, Credit: 66000 * Baht}}, res.ForeignTaxCredits)
+	})
+
+	t.Run("large foreign income does not overflow", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome:    1000000 * Baht,
+			ForeignIncomes: []ForeignIncome{{Country: "US", Amount: 100000000 * Baht, ForeignTaxPaid: 20000000 * Baht}},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []ForeignTaxCredit{{Country: "US", ForeignTaxPaid: 20000000 * Baht, Credit: 20000000 * Baht}}, res.ForeignTaxCredits)
+		assert.Equal(t, res.TaxBeforeWht-20000000*Baht, res.Tax)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			tc       TaxCalculation
+			expected error
+		}{
+			{
+				name:     "before foreign income was assessable",
+				tc:       TaxCalculation{TotalIncome: 1000 * Baht, TaxYear: 2566, ForeignIncomes: []ForeignIncome{{Country: "US", Amount: 1000 * Baht}}},
+				expected: errors.New("foreign income is assessable from tax year 2567"),
+			},
+			{
+				name:     "no country",
+				tc:       TaxCalculation{TotalIncome: 1000 * Baht, ForeignIncomes: []ForeignIncome{{Amount: 1000 * Baht}}},
+				expected: errors.New("country of foreign income is required"),
+			},
+			{
+				name:     "negative tax paid",
+				tc:       TaxCalculation{TotalIncome: 1000 * Baht, ForeignIncomes: []ForeignIncome{{Country: "US", ForeignTaxPaid: -1}}},
+				expected: errors.New("amount for foreign income from US can not be negative"),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, err := Calculate(test.tc, thaiTaxRates(), tds)
+
+				assert.Equal(t, test.expected, err)
+			})
+		}
+	})
+}
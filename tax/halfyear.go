@@ -0,0 +1,37 @@
+package tax
+
+import "fmt"
+
+// Filing types of TaxCalculation.FilingType. The half-year return (PND 94)
+// covers 40(5)-40(8) income from January to June.
+const (
+	FilingTypeAnnual   = "annual"
+	FilingTypeHalfYear = "half-year"
+)
+
+// halfYearDeductions halves the fixed caps of the rows marked for the
+// half-year return, such as the personal and family allowances.
+func halfYearDeductions(tds []TaxDeduction) []TaxDeduction {
+	halved := make([]TaxDeduction, len(tds))
+	for i, td := range tds {
+		if td.HalveForHalfYear {
+			td.MaxDeductionAmount = td.MaxDeductionAmount.MulDiv(1, 2)
+		}
+		halved[i] = td
+	}
+	return halved
+}
+
+func validationFilingType(tc TaxCalculation) error {
+	if tc.FilingType != FilingTypeHalfYear {
+		return nil
+	}
+
+	for _, in := range tc.Incomes {
+		switch in.IncomeType {
+		case IncomeSalary, IncomeServiceFee, IncomeRoyalty:
+			return fmt.Errorf("%s income is not filed on the half-year return", in.IncomeType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHalfYearFiling(t *testing.T) {
+	tds := []TaxDeduction{
+		{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht, HalveForHalfYear: true},
+		{TaxAllowanceType: "life-insurance", MaxDeductionAmount: 100000 * Baht},
+	}
+
+	t.Run("half-year return halves the marked caps", func(t *testing.T) {
+		tc := TaxCalculation{
+			FilingType: FilingTypeHalfYear,
+			Incomes:    []Income{{IncomeType: IncomeBusiness, Amount: 1200000 * Baht}},
+			Allowances: []Allowance{{AllowanceType: "life-insurance", Amount: 100000 * Baht}},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		// 1,200,000 - 720,000 - 30,000 - 100,000 = 350,000
+		assert.NoError(t, err)
+		assert.Equal(t, 30000*Baht, res.Deductions[0].Allowed)
+		assert.Equal(t, 100000*Baht, res.Deductions[1].Allowed)
+		assert.Equal(t, 350000*Baht, res.NetIncome)
+		assert.Equal(t, 20000*Baht, res.Tax)
+		assert.Equal(t, 6000*Baht, res.MinimumTax)
+	})
+
+	t.Run("annual return credits the half-year tax", func(t *testing.T) {
+		tc := TaxCalculation{
+			FilingType:  FilingTypeAnnual,
+			TotalIncome: 500000 * Baht,
+			Prepayments: []Prepayment{
+				{Source: PrepaymentWht, PayerTaxID: "0105536112014", Amount: 5000 * Baht},
+				{Source: PrepaymentHalfYear, PayerTaxID: "1101700203450", Amount: 10000 * Baht},
+			},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 29000*Baht, res.TaxBeforeWht)
+		assert.Equal(t, 14000*Baht, res.Tax)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			tc       TaxCalculation
+			expected error
+		}{
+			{
+				name:     "salary on the half-year return",
+				tc:       TaxCalculation{FilingType: FilingTypeHalfYear, Incomes: []Income{{IncomeType: IncomeSalary, Amount: 300000 * Baht}}},
+				expected: errors.New("salary income is not filed on the half-year return"),
+			},
+			{
+				name:     "half-year tax paid on the half-year return",
+				tc:       TaxCalculation{FilingType: FilingTypeHalfYear, TotalIncome: 300000 * Baht, Prepayments: []Prepayment{{Source: PrepaymentHalfYear, PayerTaxID: "1101700203450", Amount: 1000 * Baht}}},
+				expected: errors.New("half-year tax paid is credited on the annual return"),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, err := Calculate(test.tc, thaiTaxRates(), tds)
+
+				assert.Equal(t, test.expected, err)
+			})
+		}
+	})
+}
@@ -4,32 +4,30 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
-	"io"
-	"math"
 	"mime/multipart"
 	"net/http"
-	"slices"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/plakak13/assessment-tax/helper"
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
-	"golang.org/x/text/number"
 )
 
 type Handler struct {
-	store Storer
+	store   Storer
+	workers int
 }
 
 type Storer interface {
-	TaxRates() ([]TaxRate, error)
-	TaxDeductionByType(allowanceTypes []string) ([]TaxDeduction, error)
+	TaxRates(taxYear int) ([]TaxRate, error)
+	TaxDeductionByType(taxYear int, allowanceTypes []string) ([]TaxDeduction, error)
+	TaxSnapshot(taxYear int, allowanceTypes []string) (Snapshot, error)
 }
 
 func New(db Storer) *Handler {
-	return &Handler{store: db}
+	return &Handler{store: db, workers: runtime.NumCPU()}
 }
 
 func (h *Handler) CalculationHandler(c echo.Context) error {
@@ -45,300 +43,310 @@ func (h *Handler) CalculationHandler(c echo.Context) error {
 		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
 
-	allowanceType := []string{}
-	for _, v := range tc.Allowances {
-		allowanceType = append(allowanceType, v.AllowanceType)
+	if tc.TaxYear == 0 {
+		tc.TaxYear = CurrentTaxYear()
 	}
 
-	allowanceType = append(allowanceType, "personal")
-	tds, err := h.store.TaxDeductionByType(allowanceType)
+	tds, err := h.store.TaxDeductionByType(tc.TaxYear, calculationTypes(*tc))
 
 	if err != nil {
 		return helper.FailedHandler(c, err.Error())
 	}
 
-	if err = validationTax(tds, *tc); err != nil {
+	taxRates, err := h.store.TaxRates(tc.TaxYear)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+
+	res, err := Calculate(*tc, taxRates, tds)
+	if err != nil {
 		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
 
-	income := tc.TotalIncome
+	if explain, _ := strconv.ParseBool(c.QueryParam("explain")); explain {
+		res.Explanation = explanation(*tc, taxRates, res)
+	}
+
+	return helper.SuccessHandler(c, res)
+}
+
+func (h *Handler) ReverseCalculationHandler(c echo.Context) error {
 
-	maxDeduct := maxDeduct(tds, tc.Allowances)
-	income -= maxDeduct
+	rc := new(ReverseCalculation)
 
-	taxRates, err := h.store.TaxRates()
+	if err := c.Bind(rc); err != nil {
+		return helper.FailedHandler(c, "Invalid JSON", http.StatusBadRequest)
+	}
 
+	err := c.Validate(rc)
 	if err != nil {
-		return helper.FailedHandler(c, err.Error())
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
 
-	rIndex := taxRateIndex(taxRates, income)
+	if rc.TaxYear == 0 {
+		rc.TaxYear = CurrentTaxYear()
+	}
+
+	tds, err := h.store.TaxDeductionByType(rc.TaxYear, deductionTypes(rc.Allowances))
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
 
-	taxPayable := calculateTaxPayable(income, tc.WithHoldingTax, taxRates[rIndex])
+	taxRates, err := h.store.TaxRates(rc.TaxYear)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
 
-	taxRefund, taxPayable := refundTax(taxPayable)
-	taxLevels := taxLevelDetails(taxRates, rIndex, taxPayable)
+	res, err := Reverse(*rc, taxRates, tds)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	}
 
-	return helper.SuccessHandler(c, CalculationResponse{
-		Tax:       math.Round(taxPayable*100) / 100,
-		TaxRefund: math.Round(taxRefund*100) / 100,
-		TaxLevel:  taxLevels,
-	})
+	return helper.SuccessHandler(c, res)
 }
 
-func (h *Handler) CalculationCSV(c echo.Context) error {
+func (h *Handler) WithholdingHandler(c echo.Context) error {
 
-	fileUploaded, err := openFile(c)
-	if err != nil {
-		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	wc := new(WithholdingCalculation)
+
+	if err := c.Bind(wc); err != nil {
+		return helper.FailedHandler(c, "Invalid JSON", http.StatusBadRequest)
 	}
 
-	recs, err := readCSVRecords(fileUploaded)
+	err := c.Validate(wc)
 	if err != nil {
 		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
 
-	recs = removeBOM(recs)
-
-	if !validateCSVHeader(recs[0]) {
-		return helper.FailedHandler(c, "Invalid Header", http.StatusBadRequest)
+	if wc.TaxYear == 0 {
+		wc.TaxYear = CurrentTaxYear()
 	}
 
-	allowanceType := []string{"personal", "donation"}
+	tds, err := h.store.TaxDeductionByType(wc.TaxYear, deductionTypes(wc.Allowances))
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
 
-	tds, err := h.store.TaxDeductionByType(allowanceType)
+	taxRates, err := h.store.TaxRates(wc.TaxYear)
 	if err != nil {
 		return helper.FailedHandler(c, err.Error())
 	}
-	var ttis []TaxWithTotalIncome
 
-	for i, v := range recs {
-		if i == 0 {
-			continue
-		}
+	res, err := Withhold(*wc, taxRates, tds)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	}
 
-		totalIncome, wht, amount, err := parseFloatValue(v)
-		if err != nil {
-			return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
-		}
-		tc := TaxCalculation{
-			TotalIncome:    totalIncome,
-			WithHoldingTax: wht,
-			Allowances: []Allowance{
-				{
-					AllowanceType: "donation",
-					Amount:        amount,
-				},
-			},
-		}
+	return helper.SuccessHandler(c, res)
+}
 
-		if err = validationTax(tds, tc); err != nil {
-			return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
-		}
+func (h *Handler) OptimizationHandler(c echo.Context) error {
 
-		maxDeduct := maxDeduct(tds, []Allowance{
-			{
-				AllowanceType: "donation",
-				Amount:        amount,
-			},
-		})
+	or := new(OptimizationRequest)
 
-		income := totalIncome - maxDeduct
+	if err := c.Bind(or); err != nil {
+		return helper.FailedHandler(c, "Invalid JSON", http.StatusBadRequest)
+	}
 
-		taxRates, err := h.store.TaxRates()
+	err := c.Validate(or)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	}
 
-		if err != nil {
-			return helper.FailedHandler(c, err.Error())
-		}
+	if or.TaxYear == 0 {
+		or.TaxYear = CurrentTaxYear()
+	}
 
-		rIndex := taxRateIndex(taxRates, income)
+	types := append(deductionTypes(or.Allowances), optimizableAllowances...)
+	tds, err := h.store.TaxDeductionByType(or.TaxYear, types)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
 
-		taxPayable := calculateTaxPayable(income, wht, taxRates[rIndex])
+	taxRates, err := h.store.TaxRates(or.TaxYear)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
 
-		ttis = append(ttis, TaxWithTotalIncome{
-			TotalIncome: totalIncome,
-			TaxAmount:   taxPayable,
-		})
+	res, err := Optimize(*or, taxRates, tds)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
 
-	return helper.SuccessHandler(c, ttis)
+	return helper.SuccessHandler(c, res)
 }
 
-func validationTax(taxDeducts []TaxDeduction, t TaxCalculation) error {
+func (h *Handler) CompareHandler(c echo.Context) error {
 
-	if t.WithHoldingTax < 0 || t.WithHoldingTax > t.TotalIncome {
-		return errors.New("invalid withholding tax amount")
-	}
+	cr := new(ComparisonRequest)
 
-	for _, v := range t.Allowances {
-		for _, vt := range taxDeducts {
-			if vt.TaxAllowanceType == v.AllowanceType && vt.MinAmount > v.Amount {
-				return fmt.Errorf("amount for %s allowance is below the minimum threshold", v.AllowanceType)
-			}
-		}
+	if err := c.Bind(cr); err != nil {
+		return helper.FailedHandler(c, "Invalid JSON", http.StatusBadRequest)
 	}
-	return nil
-}
 
-func calculateTaxPayable(income float64, wht float64, rate TaxRate) float64 {
-
-	if math.Signbit(income) && wht == 0 {
-		return 0
+	err := c.Validate(cr)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
 
-	baseTax := 150000.0
-	taxPercent := (rate.TaxRate / 100)
-	taxPayable := ((income - baseTax) * taxPercent) - wht
-	return taxPayable
-}
-
-func maxDeduct(tds []TaxDeduction, alls []Allowance) float64 {
-	var maxDeduct float64
-	for _, td := range tds {
-		if td.TaxAllowanceType == "personal" {
-			maxDeduct += td.MaxDeductionAmount
-		}
-		for _, a := range alls {
-			if a.AllowanceType == "personal" {
-				continue
-			}
-			if td.TaxAllowanceType == a.AllowanceType {
-				if td.MaxDeductionAmount >= a.Amount {
-					maxDeduct += a.Amount
-				} else {
-					maxDeduct += td.MaxDeductionAmount
-				}
-			}
-		}
+	if cr.TaxYear == 0 {
+		cr.TaxYear = CurrentTaxYear()
 	}
-	return maxDeduct
-}
 
-func validateCSVHeader(h []string) bool {
-	expected := []string{"totalIncome", "wht", "donation"}
-	return equalSlice(expected, h)
-}
+	var types []string
+	for _, s := range cr.Scenarios {
+		types = append(types, calculationTypes(s.Calculation)...)
+	}
 
-func equalSlice(a []string, b []string) bool {
-	if len(a) != len(b) {
-		return false
+	snap, err := h.store.TaxSnapshot(cr.TaxYear, types)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
 	}
 
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
+	res, err := Compare(*cr, snap)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
-	return true
-}
 
-func praseFloat(s string) (float64, error) {
-	return strconv.ParseFloat(s, 64)
+	return helper.SuccessHandler(c, res)
 }
 
-func openFile(c echo.Context) (multipart.File, error) {
+func (h *Handler) CalculationCSV(c echo.Context) error {
 
-	file, err := c.FormFile("file")
+	fileUploaded, err := openFile(c)
 	if err != nil {
-		return nil, err
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
+	defer fileUploaded.Close()
 
-	fileUploaded, err := file.Open()
+	read := csv.NewReader(fileUploaded)
+	header, schema, err := readCSVHeader(read)
 	if err != nil {
-		return nil, err
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
 
-	defer fileUploaded.Close()
-	return fileUploaded, nil
-}
+	allowanceType := append(schema.allowanceTypes(), "personal")
+	taxYear := CurrentTaxYear()
 
-func removeBOM(recs [][]string) [][]string {
-	if len(recs) > 0 && strings.HasPrefix(recs[0][0], "\ufeff") {
-		recs[0][0] = strings.TrimPrefix(recs[0][0], "\ufeff")
+	snap, err := h.store.TaxSnapshot(taxYear, allowanceType)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+	if err := schema.known(snap.Deductions); err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
 	}
-	return recs
-}
 
-func taxLevelDetails(taxRates []TaxRate, rIndex int, taxFund float64) []TaxLevelInfo {
-	var taxLevels []TaxLevelInfo
-	p := message.NewPrinter(language.English)
-	for i, v := range taxRates {
-		tVal := 0.0
-		if v.ID == taxRates[rIndex].ID {
-			tVal = taxFund
-		}
-		if i+1 != len(taxRates) {
-			tFormat := p.Sprintf("%v-%v", number.Decimal(v.LowerBoundIncome), number.Decimal(taxRates[i+1].LowerBoundIncome-1))
+	var sheet *csvSheet
+	download := helper.Accepts(c, helper.MIMETextCSV, helper.MIMEXLSX, helper.MIMEExcel)
+	if download != "" {
+		sheet = &csvSheet{header: header}
+	}
 
-			taxLevels = append(taxLevels, TaxLevelInfo{
-				Tax:   tVal,
-				Level: tFormat,
-			})
+	rows := csvRows{
+		schema:   schema,
+		validate: c.Validate,
+		store:    h.store,
+		types:    allowanceType,
+		taxYear:  taxYear,
+		years:    map[int]csvYear{taxYear: {snap: snap}},
+		workers:  h.workers,
+	}
+	var res TaxCSVCalculation
+	err = rows.calculate(c.Request().Context(), read, func(r csvRow) { res.add(r, sheet) })
+	var readErr csvReadError
+	if errors.As(err, &readErr) {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	}
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
 
-		} else {
-			lastT := p.Sprintf("%v ขึ้นไป", number.Decimal(v.LowerBoundIncome))
+	switch download {
+	case helper.MIMETextCSV:
+		return helper.CSVHandler(c, "taxes.csv", sheet.records())
+	case helper.MIMEXLSX, helper.MIMEExcel:
+		return helper.XLSXHandler(c, "taxes.xlsx", sheet.records())
+	}
+	return helper.SuccessHandler(c, res)
+}
 
-			taxLevels = append(taxLevels, TaxLevelInfo{
-				Tax:   tVal,
-				Level: lastT,
-			})
+// fail records a row that could not be worked out and carries on with the
+// rest of the upload.
+func (t *TaxCSVCalculation) fail(row int, employeeID string, err error) {
+	t.Errors = append(t.Errors, CSVRowError{Row: row, EmployeeID: employeeID, Message: err.Error()})
+	t.Summary.RowsFailed++
+}
 
-		}
+// deductionTypes lists the deduction rows a calculation needs: the claimed
+// allowances and the personal allowance everyone gets.
+func deductionTypes(alls []Allowance) []string {
+	types := []string{}
+	for _, v := range alls {
+		types = append(types, v.AllowanceType)
 	}
-	return taxLevels
+	return append(types, "personal")
 }
 
-func refundTax(taxFund float64) (float64, float64) {
-	taxRefund := 0.0
-
-	if math.Signbit(taxFund) || taxFund == 0 {
-		taxRefund = math.Abs(taxFund)
-		taxFund = 0.0
+// calculationTypes adds what a spouse block needs to the deduction rows of the
+// taxpayer's own claims.
+func calculationTypes(tc TaxCalculation) []string {
+	types := deductionTypes(tc.Allowances)
+	if tc.Spouse != nil {
+		types = append(types, "spouse")
+		for _, a := range tc.Spouse.Allowances {
+			types = append(types, a.AllowanceType)
+		}
 	}
-	return taxRefund, taxFund
+	return types
 }
 
-func taxRateIndex(taxRates []TaxRate, income float64) int {
+// CurrentTaxYear is the Buddhist Era year used when a request does not name one.
+func CurrentTaxYear() int {
+	return time.Now().Year() + 543
+}
 
-	foundKey := slices.IndexFunc(taxRates, func(t TaxRate) bool {
-		return income <= t.LowerBoundIncome
-	})
+func validationTax(taxDeducts []TaxDeduction, t TaxCalculation) error {
 
-	rIndex := foundKey
+	if t.WithHoldingTax < 0 || prepaid(t, PrepaymentWht) > t.TotalIncome {
+		return errors.New("invalid withholding tax amount")
+	}
 
-	if foundKey > 0 {
-		rIndex = foundKey - 1
-	} else {
-		rIndex = 0
+	for _, v := range t.Allowances {
+		if v.Count < 0 {
+			return fmt.Errorf("count for %s allowance can not be negative", v.AllowanceType)
+		}
+		for _, vt := range taxDeducts {
+			if vt.TaxAllowanceType == v.AllowanceType && vt.MinAmount > v.Amount {
+				return fmt.Errorf("amount for %s allowance is below the minimum threshold", v.AllowanceType)
+			}
+		}
 	}
-	return rIndex
+	return nil
 }
 
-func readCSVRecords(fileUploaded io.Reader) ([][]string, error) {
-	read := csv.NewReader(fileUploaded)
+func openFile(c echo.Context) (multipart.File, error) {
 
-	recs, err := read.ReadAll()
+	file, err := c.FormFile("file")
 	if err != nil {
 		return nil, err
 	}
-	return recs, nil
+
+	return file.Open()
 }
 
-func parseFloatValue(v []string) (float64, float64, float64, error) {
-	totalIncome, err := praseFloat(v[0])
-	if err != nil {
-		return 0, 0, 0, errors.New("total income can not be string or empty")
+func removeBOM(header []string) []string {
+	if len(header) > 0 && strings.HasPrefix(header[0], "\ufeff") {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
 	}
+	return header
+}
 
-	wht, err := praseFloat(v[1])
-	if err != nil {
-		return 0, 0, 0, errors.New("tax with holding (twh) can not be string or empty")
-	}
+func refundTax(taxFund Money) (Money, Money) {
+	var taxRefund Money
 
-	amount, err := praseFloat(v[2])
-	if err != nil {
-		return 0, 0, 0, errors.New("donation can not be string or empty")
+	if taxFund <= 0 {
+		taxRefund = -taxFund
+		taxFund = 0
 	}
-
-	return totalIncome, wht, amount, nil
+	return taxRefund, taxFund
 }
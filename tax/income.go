@@ -0,0 +1,166 @@
+package tax
+
+import "fmt"
+
+// Income categories of Section 40 of the Revenue Code that carry a standard
+// expense deduction.
+const (
+	IncomeSalary          = "salary"           // 40(1)
+	IncomeServiceFee      = "service-fee"      // 40(2)
+	IncomeRoyalty         = "royalty"          // 40(3)
+	IncomeRent            = "rent"             // 40(5)
+	IncomeProfessionalFee = "professional-fee" // 40(6)
+	IncomeContracting     = "contracting"      // 40(7)
+	IncomeBusiness        = "business"         // 40(8)
+)
+
+const (
+	expenseFlat   = "flat"
+	expenseActual = "actual"
+)
+
+// employmentExpenseCap is shared by 40(1) and 40(2) income.
+const employmentExpenseCap = 100000 * Baht
+
+// Alternative minimum tax of Section 48(2): 0.5% of 40(2)-40(8) gross
+// income once it is over 120,000, waived when it comes to 5,000 or less.
+const (
+	minimumTaxRate      = Percent / 2
+	minimumTaxThreshold = 120000 * Baht
+	minimumTaxExemption = 5000 * Baht
+)
+
+// Methods reported in CalculationResponse.TaxMethod.
+const (
+	TaxMethodBracket = "bracket"
+	TaxMethodMinimum = "minimum"
+)
+
+var incomeSections = map[string]string{
+	IncomeSalary:          "40(1)",
+	IncomeServiceFee:      "40(2)",
+	IncomeRoyalty:         "40(3)",
+	IncomeRent:            "40(5)",
+	IncomeProfessionalFee: "40(6)",
+	IncomeContracting:     "40(7)",
+	IncomeBusiness:        "40(8)",
+}
+
+// rentExpenseRates are the flat 40(5) rates by the kind of asset let.
+var rentExpenseRates = map[string]Rate{
+	"building": 30 * Percent,
+	"farmland": 20 * Percent,
+	"land":     15 * Percent,
+	"vehicle":  30 * Percent,
+	"other":    10 * Percent,
+}
+
+// professionalExpenseRates are the flat 40(6) rates; medicine gets more than
+// law, engineering, architecture, accounting and fine arts, which are the
+// default.
+var professionalExpenseRates = map[string]Rate{
+	"medical": 60 * Percent,
+	"":        30 * Percent,
+	"other":   30 * Percent,
+}
+
+// incomeExpenses works out the standard expense of every income item and
+// returns one line per category in the order the categories first appear.
+func incomeExpenses(incomes []Income) ([]IncomeExpense, error) {
+	var expenses []IncomeExpense
+	index := map[string]int{}
+	var employmentExpense Money
+
+	for _, in := range incomes {
+		section, ok := incomeSections[in.IncomeType]
+		if !ok {
+			return nil, fmt.Errorf("unknown income type %s", in.IncomeType)
+		}
+		if in.Amount < 0 || in.ActualExpense < 0 {
+			return nil, fmt.Errorf("amount for %s income can not be negative", in.IncomeType)
+		}
+
+		expense, err := incomeExpense(in)
+		if err != nil {
+			return nil, err
+		}
+
+		if in.IncomeType == IncomeSalary || in.IncomeType == IncomeServiceFee {
+			expense = min(expense, employmentExpenseCap-employmentExpense)
+			employmentExpense += expense
+		}
+
+		i, ok := index[in.IncomeType]
+		if !ok {
+			index[in.IncomeType] = len(expenses)
+			expenses = append(expenses, IncomeExpense{IncomeType: in.IncomeType, Section: section})
+			i = len(expenses) - 1
+		}
+		expenses[i].Income += in.Amount
+		expenses[i].Expense += expense
+	}
+
+	if royalty, ok := index[IncomeRoyalty]; ok {
+		expenses[royalty].Expense = min(expenses[royalty].Expense, 100000*Baht)
+	}
+
+	return expenses, nil
+}
+
+// minimumTax is the second method of working out the tax, which only applies
+// to income other than salary. The half-year return uses half the threshold.
+func minimumTax(incomes []Income, filingType string) Money {
+	var gross Money
+	for _, in := range incomes {
+		if in.IncomeType != IncomeSalary {
+			gross += in.Amount
+		}
+	}
+	threshold := minimumTaxThreshold
+	if filingType == FilingTypeHalfYear {
+		threshold /= 2
+	}
+	if gross <= threshold {
+		return 0
+	}
+
+	tax := gross.MulRate(minimumTaxRate)
+	if tax <= minimumTaxExemption {
+		return 0
+	}
+	return tax
+}
+
+func incomeExpense(in Income) (Money, error) {
+	switch in.ExpenseMethod {
+	case "", expenseFlat:
+	case expenseActual:
+		if in.IncomeType == IncomeSalary || in.IncomeType == IncomeServiceFee || in.IncomeType == IncomeRoyalty {
+			return 0, fmt.Errorf("%s income can only use the flat expense", in.IncomeType)
+		}
+		if in.ActualExpense > in.Amount {
+			return 0, fmt.Errorf("actual expense for %s income is more than the income", in.IncomeType)
+		}
+		return in.ActualExpense, nil
+	default:
+		return 0, fmt.Errorf("unknown expense method %s", in.ExpenseMethod)
+	}
+
+	switch in.IncomeType {
+	case IncomeRent:
+		rate, ok := rentExpenseRates[in.AssetType]
+		if !ok {
+			return 0, fmt.Errorf("unknown asset type %q for rent income", in.AssetType)
+		}
+		return in.Amount.MulRate(rate), nil
+	case IncomeProfessionalFee:
+		rate, ok := professionalExpenseRates[in.Profession]
+		if !ok {
+			return 0, fmt.Errorf("unknown profession %q for professional-fee income", in.Profession)
+		}
+		return in.Amount.MulRate(rate), nil
+	case IncomeContracting, IncomeBusiness:
+		return in.Amount.MulRate(60 * Percent), nil
+	}
+	return in.Amount.MulRate(50 * Percent), nil
+}
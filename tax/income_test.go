@@ -0,0 +1,154 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncomeExpenses(t *testing.T) {
+	tests := []struct {
+		name     string
+		incomes  []Income
+		expected []IncomeExpense
+	}{
+		{
+			name:    "salary under the cap",
+			incomes: []Income{{IncomeType: IncomeSalary, Amount: 150000 * Baht}},
+			expected: []IncomeExpense{
+				{IncomeType: IncomeSalary, Section: "40(1)", Income: 150000 * Baht, Expense: 75000 * Baht},
+			},
+		},
+		{
+			name: "salary and service fee share the cap",
+			incomes: []Income{
+				{IncomeType: IncomeSalary, Amount: 150000 * Baht},
+				{IncomeType: IncomeServiceFee, Amount: 100000 * Baht},
+			},
+			expected: []IncomeExpense{
+				{IncomeType: IncomeSalary, Section: "40(1)", Income: 150000 * Baht, Expense: 75000 * Baht},
+				{IncomeType: IncomeServiceFee, Section: "40(2)", Income: 100000 * Baht, Expense: 25000 * Baht},
+			},
+		},
+		{
+			name: "rent by asset type",
+			incomes: []Income{
+				{IncomeType: IncomeRent, Amount: 100000 * Baht, AssetType: "building"},
+				{IncomeType: IncomeRent, Amount: 100000 * Baht, AssetType: "farmland"},
+			},
+			expected: []IncomeExpense{
+				{IncomeType: IncomeRent, Section: "40(5)", Income: 200000 * Baht, Expense: 50000 * Baht},
+			},
+		},
+		{
+			name:    "medical professional fee",
+			incomes: []Income{{IncomeType: IncomeProfessionalFee, Amount: 100000 * Baht, Profession: "medical"}},
+			expected: []IncomeExpense{
+				{IncomeType: IncomeProfessionalFee, Section: "40(6)", Income: 100000 * Baht, Expense: 60000 * Baht},
+			},
+		},
+		{
+			name: "business flat and actual",
+			incomes: []Income{
+				{IncomeType: IncomeBusiness, Amount: 100000 * Baht},
+				{IncomeType: IncomeBusiness, Amount: 100000 * Baht, ExpenseMethod: "actual", ActualExpense: 80000 * Baht},
+			},
+			expected: []IncomeExpense{
+				{IncomeType: IncomeBusiness, Section: "40(8)", Income: 200000 * Baht, Expense: 140000 * Baht},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := incomeExpenses(test.incomes)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, got)
+		})
+	}
+}
+
+func TestIncomeExpensesError(t *testing.T) {
+	tests := []struct {
+		name     string
+		income   Income
+		expected error
+	}{
+		{
+			name:     "unknown income type",
+			income:   Income{IncomeType: "lottery", Amount: 1000 * Baht},
+			expected: errors.New("unknown income type lottery"),
+		},
+		{
+			name:     "negative amount",
+			income:   Income{IncomeType: IncomeSalary, Amount: -1 * Baht},
+			expected: errors.New("amount for salary income can not be negative"),
+		},
+		{
+			name:     "actual expense on salary",
+			income:   Income{IncomeType: IncomeSalary, Amount: 1000 * Baht, ExpenseMethod: "actual"},
+			expected: errors.New("salary income can only use the flat expense"),
+		},
+		{
+			name:     "rent without asset type",
+			income:   Income{IncomeType: IncomeRent, Amount: 1000 * Baht},
+			expected: errors.New(`unknown asset type "" for rent income`),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := incomeExpenses([]Income{test.income})
+
+			assert.Equal(t, test.expected, err)
+		})
+	}
+}
+
+func TestMinimumTax(t *testing.T) {
+	tests := []struct {
+		name       string
+		incomes    []Income
+		filingType string
+		expected   Money
+	}{
+		{
+			name:     "salary only",
+			incomes:  []Income{{IncomeType: IncomeSalary, Amount: 5000000 * Baht}},
+			expected: 0,
+		},
+		{
+			name:     "not over the threshold",
+			incomes:  []Income{{IncomeType: IncomeServiceFee, Amount: 120000 * Baht}},
+			expected: 0,
+		},
+		{
+			name:     "exempt at 5,000",
+			incomes:  []Income{{IncomeType: IncomeBusiness, Amount: 1000000 * Baht}},
+			expected: 0,
+		},
+		{
+			name: "salary left out of the gross",
+			incomes: []Income{
+				{IncomeType: IncomeSalary, Amount: 1000000 * Baht},
+				{IncomeType: IncomeRent, Amount: 600000 * Baht, AssetType: "building"},
+				{IncomeType: IncomeBusiness, Amount: 600000 * Baht},
+			},
+			expected: 6000 * Baht,
+		},
+		{
+			name:       "half-year threshold",
+			incomes:    []Income{{IncomeType: IncomeBusiness, Amount: 1100000 * Baht}},
+			filingType: FilingTypeHalfYear,
+			expected:   5500 * Baht,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, minimumTax(test.incomes, test.filingType))
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package tax
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/plakak13/assessment-tax/helper"
+)
+
+// Job states. A job is put back in the queue when its process shuts down; a
+// job still running when its process died is claimed again, from the first
+// row, once it has gone a minute without progress.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// progressInterval is how often a running job writes its progress.
+const progressInterval = time.Second
+
+// maxJobUpload is the largest upload a job accepts, in bytes.
+const maxJobUpload = 64 << 20
+
+// rescanInterval is how often the unfinished jobs are listed again, to pick up
+// the ones left by a process that died.
+const rescanInterval = time.Minute
+
+var ErrJobNotFound = errors.New("job not found")
+
+type Job struct {
+	ID        string     `json:"id" example:"5f1c2a9e0b7d4c3a8e6f1d2b3c4a5e6f"`
+	Status    string     `json:"status" example:"running"`
+	RowsTotal int        `json:"rowsTotal" example:"20000"`
+	RowsDone  int        `json:"rowsDone" example:"12500"`
+	Error     string     `json:"error,omitempty" example:"Invalid Header"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// JobResult is what a finished job keeps: the response of the upload and the
+// sheet offered as csv or xlsx.
+type JobResult struct {
+	Result TaxCSVCalculation
+	Sheet  [][]string
+}
+
+type JobStorer interface {
+	CreateJob(id string, input []byte) error
+	Job(id string) (Job, error)
+	JobInput(id string) ([]byte, error)
+	UnfinishedJobs() ([]string, error)
+	ClaimJob(id string) (bool, error)
+	ReleaseJob(id string) error
+	StartJob(id string, rowsTotal int) error
+	UpdateJobProgress(id string, rowsDone int) error
+	FinishJob(id string, res JobResult) error
+	FailJob(id string, message string) error
+	JobResult(id string) (JobResult, error)
+}
+
+// Jobs works out uploads in the background, one job at a time with its rows
+// spread over a pool of workers.
+type Jobs struct {
+	store     Storer
+	jobs      JobStorer
+	validator echo.Validator
+	workers   int
+	maxUpload int64
+	rescan    time.Duration
+	queue     chan string
+
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+func NewJobs(store Storer, jobs JobStorer, v echo.Validator, workers int) *Jobs {
+	return &Jobs{
+		store:     store,
+		jobs:      jobs,
+		validator: v,
+		workers:   workers,
+		maxUpload: maxJobUpload,
+		rescan:    rescanInterval,
+		queue:     make(chan string, 1024),
+		pending:   map[string]bool{},
+	}
+}
+
+// Run works through the queue until ctx is done, listing the unfinished jobs
+// into it at the start and again every rescan. Other processes may list the
+// same jobs; each is worked out by the one that claims it. A job cut off by
+// ctx is put back in the queue before Run returns.
+func (j *Jobs) Run(ctx context.Context) error {
+	if err := j.requeue(); err != nil {
+		return err
+	}
+
+	tick := time.NewTicker(j.rescan)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+			if err := j.requeue(); err != nil {
+				log.Printf("jobs: %v", err)
+			}
+		case id := <-j.queue:
+			j.mu.Lock()
+			delete(j.pending, id)
+			j.mu.Unlock()
+			j.run(ctx, id)
+		}
+	}
+}
+
+func (j *Jobs) requeue() error {
+	ids, err := j.jobs.UnfinishedJobs()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		j.enqueue(id)
+	}
+	return nil
+}
+
+// enqueue queues a job unless it is already waiting in the queue.
+func (j *Jobs) enqueue(id string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.pending[id] {
+		return
+	}
+	j.pending[id] = true
+	go func() { j.queue <- id }()
+}
+
+func (j *Jobs) run(ctx context.Context, id string) {
+	claimed, err := j.jobs.ClaimJob(id)
+	if err != nil {
+		log.Printf("job %s: %v", id, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	res, err := j.calculate(ctx, id)
+	if err != nil && ctx.Err() != nil {
+		err = j.jobs.ReleaseJob(id)
+	} else if err != nil {
+		err = j.jobs.FailJob(id, err.Error())
+	} else {
+		err = j.jobs.FinishJob(id, res)
+	}
+	if err != nil {
+		log.Printf("job %s: %v", id, err)
+	}
+}
+
+// calculate works out a job the way CalculationCSV works out an upload.
+func (j *Jobs) calculate(ctx context.Context, id string) (JobResult, error) {
+	input, err := j.jobs.JobInput(id)
+	if err != nil {
+		return JobResult{}, err
+	}
+
+	read := csv.NewReader(bytes.NewReader(input))
+	header, schema, err := readCSVHeader(read)
+	if err != nil {
+		return JobResult{}, err
+	}
+
+	types := append(schema.allowanceTypes(), "personal")
+	taxYear := CurrentTaxYear()
+
+	snap, err := j.store.TaxSnapshot(taxYear, types)
+	if err != nil {
+		return JobResult{}, err
+	}
+	if err := schema.known(snap.Deductions); err != nil {
+		return JobResult{}, err
+	}
+
+	if err := j.jobs.StartJob(id, countCSVRows(input)); err != nil {
+		return JobResult{}, err
+	}
+
+	rows := csvRows{
+		schema:   schema,
+		validate: j.validator.Validate,
+		store:    j.store,
+		types:    types,
+		taxYear:  taxYear,
+		years:    map[int]csvYear{taxYear: {snap: snap}},
+		workers:  j.workers,
+	}
+	sheet := &csvSheet{header: header}
+	var res TaxCSVCalculation
+	var done atomic.Int64
+	stop := make(chan struct{})
+	go j.report(id, &done, stop)
+	err = rows.calculate(ctx, read, func(r csvRow) {
+		res.add(r, sheet)
+		done.Add(1)
+	})
+	close(stop)
+	if err != nil {
+		return JobResult{}, err
+	}
+	return JobResult{Result: res, Sheet: sheet.records()}, nil
+}
+
+// countCSVRows counts the data rows of an upload, for the progress of its job.
+func countCSVRows(input []byte) int {
+	read := csv.NewReader(bytes.NewReader(input))
+	read.ReuseRecord = true
+	n := -1
+	for {
+		_, err := read.Read()
+		var pe *csv.ParseError
+		if err != nil && !errors.As(err, &pe) {
+			return max(n, 0)
+		}
+		n++
+	}
+}
+
+func (j *Jobs) report(id string, done *atomic.Int64, stop <-chan struct{}) {
+	tick := time.NewTicker(progressInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			if err := j.jobs.UpdateJobProgress(id, int(done.Load())); err != nil {
+				log.Printf("job %s: %v", id, err)
+			}
+		}
+	}
+}
+
+func (j *Jobs) CreateJobHandler(c echo.Context) error {
+
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, j.maxUpload)
+
+	fileUploaded, err := openFile(c)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		msg := fmt.Sprintf("file is larger than %d bytes", j.maxUpload)
+		return helper.FailedHandler(c, msg, http.StatusRequestEntityTooLarge)
+	}
+	if err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	}
+	defer fileUploaded.Close()
+
+	input, err := io.ReadAll(fileUploaded)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	}
+	if _, _, err := readCSVHeader(csv.NewReader(bytes.NewReader(input))); err != nil {
+		return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+	if err := j.jobs.CreateJob(id, input); err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+	j.enqueue(id)
+
+	return helper.SuccessHandler(c, Job{ID: id, Status: JobQueued, CreatedAt: time.Now()}, http.StatusAccepted)
+}
+
+func (j *Jobs) JobHandler(c echo.Context) error {
+
+	job, err := j.jobs.Job(c.Param("id"))
+	if errors.Is(err, ErrJobNotFound) {
+		return helper.FailedHandler(c, err.Error(), http.StatusNotFound)
+	}
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+
+	return helper.SuccessHandler(c, job)
+}
+
+// JobResultHandler sends the result of a finished job as JSON, csv or xlsx,
+// by the Accept header as CalculationCSV does.
+func (j *Jobs) JobResultHandler(c echo.Context) error {
+
+	job, err := j.jobs.Job(c.Param("id"))
+	if errors.Is(err, ErrJobNotFound) {
+		return helper.FailedHandler(c, err.Error(), http.StatusNotFound)
+	}
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+	if job.Status != JobDone {
+		return helper.FailedHandler(c, fmt.Sprintf("job is %s", job.Status), http.StatusConflict)
+	}
+
+	res, err := j.jobs.JobResult(job.ID)
+	if err != nil {
+		return helper.FailedHandler(c, err.Error())
+	}
+
+	switch helper.Accepts(c, helper.MIMETextCSV, helper.MIMEXLSX, helper.MIMEExcel) {
+	case helper.MIMETextCSV:
+		return helper.CSVHandler(c, "taxes.csv", res.Sheet)
+	case helper.MIMEXLSX, helper.MIMEExcel:
+		return helper.XLSXHandler(c, "taxes.xlsx", res.Sheet)
+	}
+	return helper.SuccessHandler(c, res.Result)
+}
+
+// newJobID is random so one client cannot guess the id of another's job.
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,334 @@
+package tax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/plakak13/assessment-tax/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockJobs struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	inputs  map[string][]byte
+	results map[string]JobResult
+}
+
+func NewMockJobs() *MockJobs {
+	return &MockJobs{jobs: map[string]*Job{}, inputs: map[string][]byte{}, results: map[string]JobResult{}}
+}
+
+func (m *MockJobs) CreateJob(id string, input []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id] = &Job{ID: id, Status: JobQueued}
+	m.inputs[id] = input
+	return nil
+}
+
+func (m *MockJobs) Job(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return *j, nil
+}
+
+func (m *MockJobs) JobInput(id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inputs[id], nil
+}
+
+func (m *MockJobs) UnfinishedJobs() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id, j := range m.jobs {
+		if j.Status == JobQueued || j.Status == JobRunning {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// ClaimJob takes a queued job, or a running one whose progress is over a
+// minute old, as the postgres query does.
+func (m *MockJobs) ClaimJob(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	stale := job.UpdatedAt != nil && time.Since(*job.UpdatedAt) > time.Minute
+	if job.Status != JobQueued && !(job.Status == JobRunning && stale) {
+		return false, nil
+	}
+	job.Status = JobRunning
+	return true, nil
+}
+
+func (m *MockJobs) ReleaseJob(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].Status = JobQueued
+	m.jobs[id].RowsDone = 0
+	return nil
+}
+
+func (m *MockJobs) StartJob(id string, rowsTotal int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].Status = JobRunning
+	m.jobs[id].RowsTotal = rowsTotal
+	return nil
+}
+
+func (m *MockJobs) UpdateJobProgress(id string, rowsDone int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].RowsDone = rowsDone
+	return nil
+}
+
+func (m *MockJobs) FinishJob(id string, res JobResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].Status = JobDone
+	m.jobs[id].RowsDone = m.jobs[id].RowsTotal
+	m.results[id] = res
+	return nil
+}
+
+func (m *MockJobs) FailJob(id string, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].Status = JobFailed
+	m.jobs[id].Error = message
+	return nil
+}
+
+func (m *MockJobs) JobResult(id string) (JobResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.results[id], nil
+}
+
+func jobsStore() *MockTax {
+	return &MockTax{
+		taxRates: []TaxRate{
+			{ID: 1, LowerBoundIncome: 0.0, TaxRate: 0},
+			{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+		},
+		taxDeductions: []TaxDeduction{
+			{MaxDeductionAmount: 60000 * Baht, TaxAllowanceType: "personal"},
+			{MaxDeductionAmount: 100000 * Baht, TaxAllowanceType: "donation"},
+		},
+	}
+}
+
+func TestJobs(t *testing.T) {
+	t.Run("rows are worked out in order", func(t *testing.T) {
+		mj := NewMockJobs()
+		mj.CreateJob("a", []byte("employeeId,totalIncome,donation\nE1,500000,0\nE2,600000,abc\nE3,210000,0\nE4,1000000,100000"))
+		j := NewJobs(jobsStore(), mj, helper.NewValidator(), 3)
+
+		j.run(context.Background(), "a")
+
+		job, _ := mj.Job("a")
+		assert.Equal(t, JobDone, job.Status)
+		assert.Equal(t, 4, job.RowsTotal)
+
+		res := mj.results["a"].Result
+		assert.Equal(t, []TaxWithTotalIncome{
+			{Row: 2, EmployeeID: "E1", TotalIncome: 500000 * Baht, TaxAmount: 29000 * Baht},
+			{Row: 4, EmployeeID: "E3", TotalIncome: 210000 * Baht},
+			{Row: 5, EmployeeID: "E4", TotalIncome: 1000000 * Baht, TaxAmount: 69000 * Baht},
+		}, res.Taxes)
+		assert.Equal(t, []CSVRowError{{Row: 3, EmployeeID: "E2", Message: "donation can not be string or empty"}}, res.Errors)
+		assert.Equal(t, CSVSummary{RowsOK: 3, RowsFailed: 1}, res.Summary)
+		assert.Len(t, mj.results["a"].Sheet, 5)
+	})
+
+	t.Run("job claimed elsewhere is left alone", func(t *testing.T) {
+		mj := NewMockJobs()
+		mj.CreateJob("c", []byte("totalIncome\n500000"))
+		mj.jobs["c"].Status = JobRunning
+		j := NewJobs(jobsStore(), mj, helper.NewValidator(), 2)
+
+		j.run(context.Background(), "c")
+
+		job, _ := mj.Job("c")
+		assert.Equal(t, JobRunning, job.Status)
+		assert.Empty(t, mj.results)
+	})
+
+	t.Run("stale running job is picked up by a later rescan", func(t *testing.T) {
+		mj := NewMockJobs()
+		mj.CreateJob("s", []byte("totalIncome\n500000"))
+		now := time.Now()
+		mj.jobs["s"].Status = JobRunning
+		mj.jobs["s"].UpdatedAt = &now
+		j := NewJobs(jobsStore(), mj, helper.NewValidator(), 2)
+		j.rescan = 10 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go j.Run(ctx)
+
+		time.Sleep(50 * time.Millisecond)
+		job, _ := mj.Job("s")
+		assert.Equal(t, JobRunning, job.Status)
+
+		mj.mu.Lock()
+		stale := now.Add(-2 * time.Minute)
+		mj.jobs["s"].UpdatedAt = &stale
+		mj.mu.Unlock()
+
+		assert.Eventually(t, func() bool {
+			job, _ := mj.Job("s")
+			return job.Status == JobDone
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("job cut off by shutdown goes back to the queue", func(t *testing.T) {
+		mj := NewMockJobs()
+		mj.CreateJob("q", []byte("totalIncome\n500000"))
+		j := NewJobs(jobsStore(), mj, helper.NewValidator(), 2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		j.run(ctx, "q")
+
+		job, _ := mj.Job("q")
+		assert.Equal(t, JobQueued, job.Status)
+		assert.Empty(t, job.Error)
+		assert.Empty(t, mj.results)
+	})
+
+	t.Run("bad header fails the job", func(t *testing.T) {
+		mj := NewMockJobs()
+		mj.CreateJob("b", []byte("totalIncome,lottery\n500000,0"))
+		j := NewJobs(jobsStore(), mj, helper.NewValidator(), 2)
+
+		j.run(context.Background(), "b")
+
+		job, _ := mj.Job("b")
+		assert.Equal(t, JobFailed, job.Status)
+		assert.Equal(t, "unknown column lottery", job.Error)
+	})
+}
+
+func TestJobHandlers(t *testing.T) {
+	e := echo.New()
+	e.Validator = helper.NewValidator()
+
+	mj := NewMockJobs()
+	j := NewJobs(jobsStore(), mj, e.Validator, 2)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.csv")
+	part.Write([]byte("totalIncome,wht,donation\n500000,0,0"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/tax/jobs", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+
+	err := j.CreateJobHandler(e.NewContext(req, rec))
+
+	var created Job
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, JobQueued, created.Status)
+	assert.Len(t, created.ID, 32)
+
+	get := func(handler echo.HandlerFunc, id string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/tax/jobs/"+id, nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		assert.NoError(t, handler(c))
+		return rec
+	}
+
+	rec = get(j.JobResultHandler, created.ID, "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "job is queued", jsonMashal(rec.Body.Bytes()).Message)
+
+	j.run(context.Background(), <-j.queue)
+
+	rec = get(j.JobHandler, created.ID, "")
+	var job Job
+	json.Unmarshal(rec.Body.Bytes(), &job)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, JobDone, job.Status)
+	assert.Equal(t, 1, job.RowsDone)
+
+	rec = get(j.JobResultHandler, created.ID, "")
+	var res TaxCSVCalculation
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 29000*Baht, res.Taxes[0].TaxAmount)
+
+	rec = get(j.JobResultHandler, created.ID, helper.MIMETextCSV)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, helper.MIMETextCSV, rec.Header().Get(echo.HeaderContentType))
+
+	rec = get(j.JobHandler, "missing", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "job not found", jsonMashal(rec.Body.Bytes()).Message)
+
+	t.Run("upload over the limit", func(t *testing.T) {
+		j := NewJobs(jobsStore(), NewMockJobs(), e.Validator, 2)
+		j.maxUpload = 64
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "test.csv")
+		part.Write(bytes.Repeat([]byte("500000\n"), 100))
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/tax/jobs", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+
+		err := j.CreateJobHandler(e.NewContext(req, rec))
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, "file is larger than 64 bytes", jsonMashal(rec.Body.Bytes()).Message)
+	})
+
+	t.Run("invalid header is rejected before queueing", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "test.csv")
+		part.Write([]byte("employeeId,wht\nE1,0"))
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/tax/jobs", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+
+		err := j.CreateJobHandler(e.NewContext(req, rec))
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "Invalid Header", jsonMashal(rec.Body.Bytes()).Message)
+	})
+}
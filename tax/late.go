@@ -0,0 +1,54 @@
+package tax
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	// surchargeRate is charged per month or part of a month under Section 27.
+	surchargeRate = 150
+)
+
+// lateCharges works out the surcharge on tax paid after the due date, capped
+// at the tax itself, and adds the penalty the Revenue Department imposed.
+func lateCharges(lf *LateFiling, tax Money) (*LateCharges, error) {
+	due, err := time.Parse(dateLayout, lf.DueDate)
+	if err != nil {
+		return nil, errors.New("invalid due date")
+	}
+	paid, err := time.Parse(dateLayout, lf.PaymentDate)
+	if err != nil {
+		return nil, errors.New("invalid payment date")
+	}
+	if lf.Penalty < 0 {
+		return nil, errors.New("penalty can not be negative")
+	}
+
+	months := monthsLate(due, paid)
+	surcharge := min(tax.MulRate(surchargeRate*Rate(months)), tax)
+	return &LateCharges{
+		MonthsLate: months,
+		Surcharge:  surcharge,
+		Penalty:    lf.Penalty,
+		TotalDue:   tax + surcharge + lf.Penalty,
+	}, nil
+}
+
+// monthsLate counts the months and part of a month from due to paid. Each
+// month ends on the due day, or on the last day of a shorter month, so a
+// return due on 31 March has its first month end on 30 April.
+func monthsLate(due, paid time.Time) int {
+	if !paid.After(due) {
+		return 0
+	}
+
+	months := (paid.Year()-due.Year())*12 + int(paid.Month()-due.Month())
+	lastDay := time.Date(paid.Year(), paid.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if paid.Day() > min(due.Day(), lastDay) {
+		months++
+	}
+	return months
+}
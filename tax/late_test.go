@@ -0,0 +1,88 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLateCharges(t *testing.T) {
+	tests := []struct {
+		name     string
+		lf       LateFiling
+		tax      Money
+		expected *LateCharges
+	}{
+		{
+			name:     "paid on time",
+			lf:       LateFiling{DueDate: "2025-03-31", PaymentDate: "2025-03-31"},
+			tax:      29000 * Baht,
+			expected: &LateCharges{TotalDue: 29000 * Baht},
+		},
+		{
+			name:     "one day is a whole month",
+			lf:       LateFiling{DueDate: "2025-03-31", PaymentDate: "2025-04-01"},
+			tax:      29000 * Baht,
+			expected: &LateCharges{MonthsLate: 1, Surcharge: 435 * Baht, TotalDue: 29435 * Baht},
+		},
+		{
+			name:     "whole month ends on the last day of a shorter month",
+			lf:       LateFiling{DueDate: "2025-03-31", PaymentDate: "2025-04-30"},
+			tax:      29000 * Baht,
+			expected: &LateCharges{MonthsLate: 1, Surcharge: 435 * Baht, TotalDue: 29435 * Baht},
+		},
+		{
+			name:     "a day past a month from month end",
+			lf:       LateFiling{DueDate: "2025-03-31", PaymentDate: "2025-05-01"},
+			tax:      29000 * Baht,
+			expected: &LateCharges{MonthsLate: 2, Surcharge: 870 * Baht, TotalDue: 29870 * Baht},
+		},
+		{
+			name:     "mid month due date",
+			lf:       LateFiling{DueDate: "2025-01-15", PaymentDate: "2025-03-16"},
+			tax:      29000 * Baht,
+			expected: &LateCharges{MonthsLate: 3, Surcharge: 1305 * Baht, TotalDue: 30305 * Baht},
+		},
+		{
+			name:     "part of a second month with a penalty",
+			lf:       LateFiling{DueDate: "2025-03-31", PaymentDate: "2025-05-15", Penalty: 200 * Baht},
+			tax:      29000 * Baht,
+			expected: &LateCharges{MonthsLate: 2, Surcharge: 870 * Baht, Penalty: 200 * Baht, TotalDue: 30070 * Baht},
+		},
+		{
+			name:     "surcharge capped at the tax",
+			lf:       LateFiling{DueDate: "2018-03-31", PaymentDate: "2025-03-31"},
+			tax:      1000 * Baht,
+			expected: &LateCharges{MonthsLate: 84, Surcharge: 1000 * Baht, TotalDue: 2000 * Baht},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := lateCharges(&test.lf, test.tax)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, got)
+		})
+	}
+
+	t.Run("invalid date", func(t *testing.T) {
+		_, err := lateCharges(&LateFiling{DueDate: "31/03/2025", PaymentDate: "2025-04-01"}, 0)
+
+		assert.Equal(t, errors.New("invalid due date"), err)
+	})
+
+	t.Run("charged on the tax left after withholding", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome:    500000 * Baht,
+			WithHoldingTax: 9000 * Baht,
+			LateFiling:     &LateFiling{DueDate: "2025-03-31", PaymentDate: "2025-04-10"},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), []TaxDeduction{{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &LateCharges{MonthsLate: 1, Surcharge: 300 * Baht, TotalDue: 20300 * Baht}, res.LateCharges)
+	})
+}
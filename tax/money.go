@@ -0,0 +1,174 @@
+package tax
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+// Money is an amount in satang, so every figure in the pipeline stays exact
+// from request binding to the response.
+type Money int64
+
+// Rate is a percentage in hundredths of a percent (10.00% is 1000).
+type Rate int64
+
+const (
+	Baht    Money = 100
+	Percent Rate  = 100
+)
+
+// decimal is the only form an amount is read in: no exponents, fractions or
+// hexadecimal.
+var decimal = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)
+
+// ParseMoney reads a decimal baht amount exactly. Digits past the satang are
+// truncated.
+func ParseMoney(s string) (Money, error) {
+	v, err := parseFixed(s)
+	return Money(v), err
+}
+
+// ParseRate reads a decimal percentage such as "15.00".
+func ParseRate(s string) (Rate, error) {
+	v, err := parseFixed(s)
+	return Rate(v), err
+}
+
+// MulRate applies a percentage.
+func (m Money) MulRate(r Rate) Money {
+	return m.MulDiv(int64(r), int64(100*Percent))
+}
+
+// MulDiv is m x num / den without overflowing on the way. Every share of an
+// amount goes through it, so this is the only place fractions of a satang
+// appear, and they are truncated as the Revenue Department does.
+func (m Money) MulDiv(num, den int64) Money {
+	v := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(num))
+	v.Quo(v, big.NewInt(den))
+	if !v.IsInt64() {
+		if v.Sign() < 0 {
+			return Money(math.MinInt64)
+		}
+		return Money(math.MaxInt64)
+	}
+	return Money(v.Int64())
+}
+
+// WholeBaht drops the satang, for amounts the Revenue Department takes in
+// whole baht.
+func (m Money) WholeBaht() Money {
+	return m / Baht * Baht
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / float64(Baht)
+}
+
+func (m Money) String() string {
+	return formatFixed(int64(m))
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalFixed(b)
+	*m = Money(v)
+	return err
+}
+
+func (m *Money) Scan(src any) error {
+	v, err := scanFixed(src)
+	*m = Money(v)
+	return err
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (r Rate) Float64() float64 {
+	return float64(r) / float64(Percent)
+}
+
+func (r Rate) String() string {
+	return formatFixed(int64(r))
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalFixed(b)
+	*r = Rate(v)
+	return err
+}
+
+func (r *Rate) Scan(src any) error {
+	v, err := scanFixed(src)
+	*r = Rate(v)
+	return err
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+func parseFixed(s string) (int64, error) {
+	if !decimal.MatchString(s) {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	rat, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	scaled := new(big.Int).Mul(rat.Num(), big.NewInt(100))
+	scaled.Quo(scaled, rat.Denom())
+	if !scaled.IsInt64() {
+		return 0, fmt.Errorf("decimal %q out of range", s)
+	}
+	return scaled.Int64(), nil
+}
+
+func formatFixed(v int64) string {
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+func unmarshalFixed(b []byte) (int64, error) {
+	if bytes.Equal(b, []byte("null")) {
+		return 0, nil
+	}
+	if s, err := strconv.Unquote(string(b)); err == nil {
+		return parseFixed(s)
+	}
+	return parseFixed(string(b))
+}
+
+func scanFixed(src any) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case []byte:
+		return parseFixed(string(v))
+	case string:
+		return parseFixed(v)
+	case int64:
+		return v * 100, nil
+	case float64:
+		return parseFixed(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return 0, fmt.Errorf("cannot scan %T into a decimal amount", src)
+}
@@ -0,0 +1,105 @@
+package tax
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Money
+		hasError bool
+	}{
+		{name: "whole baht", input: "500000", expected: 500000 * Baht},
+		{name: "satang", input: "1234.56", expected: 123456},
+		{name: "fraction of satang is truncated", input: "0.019", expected: 1},
+		{name: "negative", input: "-10.5", expected: -1050},
+		{name: "exponent", input: "1e5", hasError: true},
+		{name: "hexadecimal", input: "0x10", hasError: true},
+		{name: "fraction", input: "1/3", hasError: true},
+		{name: "empty", input: "", hasError: true},
+		{name: "text", input: "abc", hasError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseMoney(test.input)
+			if test.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, got)
+		})
+	}
+}
+
+func TestMoneyMulRate(t *testing.T) {
+	assert.Equal(t, 29000*Baht, (290000 * Baht).MulRate(10*Percent))
+	assert.Equal(t, Money(15), Money(101).MulRate(15*Percent))
+	assert.Equal(t, Money(0), Money(9).MulRate(10*Percent))
+}
+
+func TestMoneyMulDiv(t *testing.T) {
+	assert.Equal(t, Money(33), Money(100).MulDiv(1, 3))
+	assert.Equal(t, Money(-33), Money(-100).MulDiv(1, 3))
+	assert.Equal(t, 50000000*Baht, (100000000*Baht).MulDiv(int64(100000000*Baht), int64(200000000*Baht)))
+}
+
+func TestMoneyWholeBaht(t *testing.T) {
+	assert.Equal(t, 2416*Baht, (2416*Baht + 66).WholeBaht())
+	assert.Equal(t, 2416*Baht, (2416 * Baht).WholeBaht())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var tc TaxCalculation
+	err := json.Unmarshal([]byte(`{"totalIncome": 500000.10, "wht": "0.2"}`), &tc)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Money(50000010), tc.TotalIncome)
+	assert.Equal(t, Money(20), tc.WithHoldingTax)
+
+	b, err := json.Marshal(TaxLevelInfo{Level: "0-150,000", Tax: -105})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"level":"0-150,000","tax":-1.05}`, string(b))
+
+	err = json.Unmarshal([]byte(`{"totalIncome": true}`), &tc)
+	assert.Error(t, err)
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      any
+		expected Money
+	}{
+		{name: "postgres decimal", src: []byte("150001.00"), expected: 150001 * Baht},
+		{name: "string", src: "0.10", expected: 10},
+		{name: "float", src: 0.1, expected: 10},
+		{name: "integer", src: int64(60000), expected: 60000 * Baht},
+		{name: "null", src: nil, expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var m Money
+			assert.NoError(t, m.Scan(test.src))
+			assert.Equal(t, test.expected, m)
+		})
+	}
+
+	var r Rate
+	assert.NoError(t, r.Scan([]byte("15.00")))
+	assert.Equal(t, 15*Percent, r)
+
+	var m Money
+	assert.Error(t, m.Scan(true))
+
+	v, err := (70000 * Baht).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "70000.00", v)
+}
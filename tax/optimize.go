@@ -0,0 +1,130 @@
+package tax
+
+import (
+	"errors"
+	"math/big"
+	"slices"
+)
+
+// optimizableAllowances are the allowances a taxpayer can still buy into at
+// year end.
+var optimizableAllowances = []string{
+	"rmf", "ssf", "thai-esg", "pension-insurance", "life-insurance", "health-insurance", "k-receipt", "donation", "donation-education",
+}
+
+// maxAllowanceAmount is more than any allowance cap, so claiming it shows the
+// most an allowance can give.
+const maxAllowanceAmount = 1000000000000 * Baht
+
+// Optimize reports how much more could go into each allowance before its cap
+// and what that saves, then spends the budget greedily on whichever allowance
+// saves the most per baht until the budget or the headroom runs out. The
+// headroom is worked out again after every pick since allowances share group
+// ceilings.
+func Optimize(or OptimizationRequest, rates []TaxRate, tds []TaxDeduction) (OptimizationResponse, error) {
+	if or.Budget < 0 {
+		return OptimizationResponse{}, errors.New("budget can not be negative")
+	}
+
+	base, err := Calculate(or.TaxCalculation, rates, tds)
+	if err != nil {
+		return OptimizationResponse{}, err
+	}
+
+	var candidates []string
+	for _, t := range optimizableAllowances {
+		if slices.ContainsFunc(tds, func(td TaxDeduction) bool { return td.TaxAllowanceType == t }) {
+			candidates = append(candidates, t)
+		}
+	}
+
+	res := OptimizationResponse{Tax: base.TaxBeforeWht, Budget: or.Budget}
+	for _, t := range candidates {
+		s := headroom(or.TaxCalculation, t, rates, tds)
+		if s.AdditionalAmount > 0 {
+			res.Suggestions = append(res.Suggestions, s)
+		}
+	}
+
+	tc := or.TaxCalculation
+	budget := or.Budget
+	for budget > 0 {
+		var best Suggestion
+		for _, t := range candidates {
+			s := headroom(tc, t, rates, tds)
+			if s.TaxSaved == 0 {
+				continue
+			}
+			if best.TaxSaved == 0 || savesMore(s, best) {
+				best = s
+			}
+		}
+		if best.TaxSaved == 0 {
+			break
+		}
+
+		before, _ := Calculate(tc, rates, tds)
+		best.AdditionalAmount = min(best.AdditionalAmount, budget)
+		tc.Allowances = append(slices.Clone(tc.Allowances), Allowance{AllowanceType: best.AllowanceType, Amount: best.AdditionalAmount})
+		after, err := Calculate(tc, rates, tds)
+		if err != nil {
+			return OptimizationResponse{}, err
+		}
+		best.TaxSaved = before.TaxBeforeWht - after.TaxBeforeWht
+
+		res.Plan = append(res.Plan, best)
+		res.PlanTaxSaved += best.TaxSaved
+		budget -= best.AdditionalAmount
+	}
+
+	return res, nil
+}
+
+// headroom finds the least extra amount claimed under allowanceType that
+// brings its allowed deduction to the most it can be, and the tax it saves.
+func headroom(tc TaxCalculation, allowanceType string, rates []TaxRate, tds []TaxDeduction) Suggestion {
+	claim := func(extra Money) (Money, Money, bool) {
+		c := tc
+		if extra > 0 {
+			c.Allowances = append(slices.Clone(tc.Allowances), Allowance{AllowanceType: allowanceType, Amount: extra})
+		}
+		res, err := Calculate(c, rates, tds)
+		if err != nil {
+			return 0, 0, false
+		}
+		for _, d := range res.Deductions {
+			if d.AllowanceType == allowanceType {
+				return d.Allowed, res.TaxBeforeWht, true
+			}
+		}
+		return 0, res.TaxBeforeWht, true
+	}
+
+	current, tax, _ := claim(0)
+	most, _, ok := claim(maxAllowanceAmount)
+	if !ok || most <= current {
+		return Suggestion{AllowanceType: allowanceType}
+	}
+
+	lo, hi := Money(0), maxAllowanceAmount
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		if allowed, _, ok := claim(mid); ok && allowed >= most {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	_, taxAfter, _ := claim(hi)
+	return Suggestion{AllowanceType: allowanceType, AdditionalAmount: hi, TaxSaved: tax - taxAfter}
+}
+
+// savesMore tells whether a saves more tax per baht than b. The ratios are
+// compared by cross-multiplying, so equal ratios always tie and the earlier
+// candidate is kept.
+func savesMore(a, b Suggestion) bool {
+	lhs := new(big.Int).Mul(big.NewInt(int64(a.TaxSaved)), big.NewInt(int64(b.AdditionalAmount)))
+	rhs := new(big.Int).Mul(big.NewInt(int64(b.TaxSaved)), big.NewInt(int64(a.AdditionalAmount)))
+	return lhs.Cmp(rhs) > 0
+}
@@ -0,0 +1,76 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimize(t *testing.T) {
+	tds := []TaxDeduction{
+		{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht},
+		{TaxAllowanceType: "rmf", MaxDeductionAmount: 500000 * Baht, MaxPercentOfIncome: 30 * Percent, DeductionGroup: "retirement", GroupMaxAmount: 500000 * Baht},
+		{TaxAllowanceType: "ssf", MaxDeductionAmount: 200000 * Baht, MaxPercentOfIncome: 30 * Percent, DeductionGroup: "retirement", GroupMaxAmount: 500000 * Baht},
+		{TaxAllowanceType: "life-insurance", MaxDeductionAmount: 100000 * Baht, DeductionGroup: "insurance", GroupMaxAmount: 100000 * Baht},
+		{TaxAllowanceType: "donation", MaxPercentOfIncome: 10 * Percent, AfterDeductions: true, DeductionRate: 100 * Percent},
+	}
+
+	t.Run("headroom and plan under budget", func(t *testing.T) {
+		or := OptimizationRequest{
+			TaxCalculation: TaxCalculation{TotalIncome: 1000000 * Baht},
+			Budget:         350000 * Baht,
+		}
+
+		res, err := Optimize(or, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 101000*Baht, res.Tax)
+		assert.Equal(t, []Suggestion{
+			{AllowanceType: "rmf", AdditionalAmount: 300000 * Baht, TaxSaved: 45000 * Baht},
+			{AllowanceType: "ssf", AdditionalAmount: 200000 * Baht, TaxSaved: 30000 * Baht},
+			{AllowanceType: "life-insurance", AdditionalAmount: 100000 * Baht, TaxSaved: 15000 * Baht},
+			{AllowanceType: "donation", AdditionalAmount: 94000 * Baht, TaxSaved: 14100 * Baht},
+		}, res.Suggestions)
+		assert.Equal(t, []Suggestion{
+			{AllowanceType: "rmf", AdditionalAmount: 300000 * Baht, TaxSaved: 45000 * Baht},
+			{AllowanceType: "life-insurance", AdditionalAmount: 50000 * Baht, TaxSaved: 7500 * Baht},
+		}, res.Plan)
+		assert.Equal(t, 52500*Baht, res.PlanTaxSaved)
+	})
+
+	t.Run("allowance already at its cap", func(t *testing.T) {
+		or := OptimizationRequest{
+			TaxCalculation: TaxCalculation{
+				TotalIncome: 1000000 * Baht,
+				Allowances:  []Allowance{{AllowanceType: "life-insurance", Amount: 100000 * Baht}},
+			},
+		}
+
+		res, err := Optimize(or, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		for _, s := range res.Suggestions {
+			assert.NotEqual(t, "life-insurance", s.AllowanceType)
+		}
+		assert.Empty(t, res.Plan)
+	})
+
+	t.Run("negative budget", func(t *testing.T) {
+		_, err := Optimize(OptimizationRequest{TaxCalculation: TaxCalculation{TotalIncome: 1000000 * Baht}, Budget: -1}, thaiTaxRates(), tds)
+
+		assert.Equal(t, errors.New("budget can not be negative"), err)
+	})
+}
+
+func TestSavesMore(t *testing.T) {
+	half := Suggestion{AdditionalAmount: 2 * Baht, TaxSaved: 1 * Baht}
+
+	assert.True(t, savesMore(Suggestion{AdditionalAmount: 3 * Baht, TaxSaved: 2 * Baht}, half))
+	assert.False(t, savesMore(Suggestion{AdditionalAmount: 4 * Baht, TaxSaved: 2 * Baht}, half))
+	assert.False(t, savesMore(half, Suggestion{AdditionalAmount: 4 * Baht, TaxSaved: 2 * Baht}))
+
+	// 2^53+1 over 2^53 rounds to exactly 1 as a float64.
+	near := Suggestion{AdditionalAmount: 1 << 53, TaxSaved: 1<<53 + 1}
+	assert.True(t, savesMore(near, Suggestion{AdditionalAmount: 1, TaxSaved: 1}))
+}
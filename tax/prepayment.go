@@ -0,0 +1,90 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sources of tax paid or credited before the return, in the order they are
+// reported. Only withholding and half-year entries are sent in; dividend
+// credits are worked out from the dividends.
+const (
+	PrepaymentWht      = "wht"
+	PrepaymentHalfYear = "half-year"
+	PrepaymentDividend = "dividend"
+)
+
+var prepaymentSources = []string{PrepaymentWht, PrepaymentHalfYear, PrepaymentDividend}
+
+func validationPrepayments(tc TaxCalculation) error {
+	if tc.WithHoldingTax != 0 && len(tc.Prepayments) > 0 {
+		return errors.New("wht is deprecated, send it as a wht prepayment with its payer tax id")
+	}
+	for _, p := range tc.Prepayments {
+		switch p.Source {
+		case PrepaymentWht, PrepaymentHalfYear:
+		default:
+			return fmt.Errorf("unknown prepayment source %s", p.Source)
+		}
+		if p.Amount < 0 {
+			return fmt.Errorf("amount for %s prepayment can not be negative", p.Source)
+		}
+		if !validTaxID(p.PayerTaxID) {
+			return fmt.Errorf("invalid payer tax id %s", p.PayerTaxID)
+		}
+		if p.Source == PrepaymentHalfYear && tc.FilingType == FilingTypeHalfYear {
+			return errors.New("half-year tax paid is credited on the annual return")
+		}
+	}
+	for _, d := range tc.Dividends {
+		if d.PayerTaxID != "" && !validTaxID(d.PayerTaxID) {
+			return fmt.Errorf("invalid payer tax id %s", d.PayerTaxID)
+		}
+	}
+	return nil
+}
+
+// prepaid sums the entries from one source. A deprecated wht amount, only
+// accepted without entries, stands in for them.
+func prepaid(tc TaxCalculation, source string) Money {
+	var total Money
+	if source == PrepaymentWht {
+		total = tc.WithHoldingTax
+	}
+	for _, p := range tc.Prepayments {
+		if p.Source == source {
+			total += p.Amount
+		}
+	}
+	return total
+}
+
+// prepaymentTotals lists what each source credits against the tax, leaving
+// out sources with nothing.
+func prepaymentTotals(amounts map[string]Money) []PrepaymentTotal {
+	var totals []PrepaymentTotal
+	for _, source := range prepaymentSources {
+		if amounts[source] != 0 {
+			totals = append(totals, PrepaymentTotal{Source: source, Amount: amounts[source]})
+		}
+	}
+	return totals
+}
+
+// validTaxID checks the 13 digits and check digit of a Thai tax id.
+func validTaxID(id string) bool {
+	if len(id) != 13 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 13; i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return false
+		}
+		if i < 12 {
+			sum += int(id[i]-'0') * (13 - i)
+		}
+	}
+	return (11-sum%11)%10 == int(id[12]-'0')
+}
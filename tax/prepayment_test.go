@@ -0,0 +1,111 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepayments(t *testing.T) {
+	tds := []TaxDeduction{{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}}
+
+	t.Run("entries are summed by source", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome: 500000 * Baht,
+			Prepayments: []Prepayment{
+				{Source: PrepaymentWht, PayerTaxID: "0105536112014", Amount: 10000 * Baht},
+				{Source: PrepaymentWht, PayerTaxID: "0107537000254", Amount: 5000 * Baht},
+				{Source: PrepaymentHalfYear, PayerTaxID: "1101700203450", Amount: 10000 * Baht},
+			},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		// 440,000 net: 29,000 - 15,000 - 10,000
+		assert.NoError(t, err)
+		assert.Equal(t, 29000*Baht, res.TaxBeforeWht)
+		assert.Equal(t, 4000*Baht, res.Tax)
+		assert.Equal(t, []PrepaymentTotal{
+			{Source: PrepaymentWht, Amount: 15000 * Baht},
+			{Source: PrepaymentHalfYear, Amount: 10000 * Baht},
+		}, res.Prepayments)
+	})
+
+	t.Run("deprecated wht without entries", func(t *testing.T) {
+		tc := TaxCalculation{TotalIncome: 500000 * Baht, WithHoldingTax: 25000 * Baht}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []PrepaymentTotal{{Source: PrepaymentWht, Amount: 25000 * Baht}}, res.Prepayments)
+	})
+
+	t.Run("tax credits are reported with the prepayments", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome:      500000 * Baht,
+			Dividends:        []Dividend{{Amount: 80000 * Baht, Withheld: 8000 * Baht, CorporateTaxRate: 20 * Percent, PayerTaxID: "0107537000254"}},
+			IncludeDividends: true,
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []PrepaymentTotal{{Source: PrepaymentDividend, Amount: 28000 * Baht}}, res.Prepayments)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			wht         Money
+			prepayments []Prepayment
+			dividends   []Dividend
+			expected    error
+		}{
+			{
+				name:        "wht sent with prepayments",
+				wht:         2000 * Baht,
+				prepayments: []Prepayment{{Source: PrepaymentWht, PayerTaxID: "0105536112014", Amount: 8000 * Baht}},
+				expected:    errors.New("wht is deprecated, send it as a wht prepayment with its payer tax id"),
+			},
+			{
+				name:        "unknown source",
+				prepayments: []Prepayment{{Source: PrepaymentDividend, PayerTaxID: "0105536112014", Amount: 1000 * Baht}},
+				expected:    errors.New("unknown prepayment source dividend"),
+			},
+			{
+				name:        "negative amount",
+				prepayments: []Prepayment{{Source: PrepaymentWht, PayerTaxID: "0105536112014", Amount: -1}},
+				expected:    errors.New("amount for wht prepayment can not be negative"),
+			},
+			{
+				name:        "bad check digit",
+				prepayments: []Prepayment{{Source: PrepaymentWht, PayerTaxID: "0105536112015", Amount: 1000 * Baht}},
+				expected:    errors.New("invalid payer tax id 0105536112015"),
+			},
+			{
+				name:      "bad dividend payer",
+				dividends: []Dividend{{Amount: 1000 * Baht, Withheld: 100 * Baht, CorporateTaxRate: 20 * Percent, PayerTaxID: "12345"}},
+				expected:  errors.New("invalid payer tax id 12345"),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				tc := TaxCalculation{TotalIncome: 500000 * Baht, WithHoldingTax: test.wht, Prepayments: test.prepayments, Dividends: test.dividends}
+
+				_, err := Calculate(tc, thaiTaxRates(), tds)
+
+				assert.Equal(t, test.expected, err)
+			})
+		}
+	})
+}
+
+func TestValidTaxID(t *testing.T) {
+	assert.True(t, validTaxID("0105536112014"))
+	assert.True(t, validTaxID("1101700203450"))
+	assert.False(t, validTaxID("1101700203451"))
+	assert.False(t, validTaxID("110170020345"))
+	assert.False(t, validTaxID("11017002034a0"))
+}
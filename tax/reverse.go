@@ -0,0 +1,71 @@
+package tax
+
+import "errors"
+
+// maxReverseIncome bounds the search so an unreachable target fails instead
+// of overflowing.
+const maxReverseIncome = 1000000000000 * Baht
+
+// Reverse finds the lowest income at which the forward calculation gives
+// the target after-tax income or the target tax. The income is taken as net
+// of expenses unless rc names the income type to gross up.
+func Reverse(rc ReverseCalculation, rates []TaxRate, tds []TaxDeduction) (ReverseCalculationResponse, error) {
+	if (rc.TargetNetIncome > 0) == (rc.TargetTax > 0) {
+		return ReverseCalculationResponse{}, errors.New("either target net income or target tax is required")
+	}
+
+	calculate := func(income Money) (CalculationResponse, error) {
+		tc := TaxCalculation{TotalIncome: income, Allowances: rc.Allowances, TaxYear: rc.TaxYear}
+		if rc.IncomeType != "" {
+			tc = TaxCalculation{Incomes: []Income{{IncomeType: rc.IncomeType, Amount: income}}, Allowances: rc.Allowances, TaxYear: rc.TaxYear}
+		}
+		return Calculate(tc, rates, tds)
+	}
+	reached := func(income Money, res CalculationResponse) bool {
+		if rc.TargetTax > 0 {
+			return res.TaxBeforeWht >= rc.TargetTax
+		}
+		return income-res.TaxBeforeWht >= rc.TargetNetIncome
+	}
+
+	// Tax never goes down as income goes up, so find an income that is
+	// enough by doubling and then narrow it down to the satang.
+	lo, hi := Money(0), max(rc.TargetNetIncome, rc.TargetTax)
+	for {
+		res, err := calculate(hi)
+		if err != nil {
+			return ReverseCalculationResponse{}, err
+		}
+		if reached(hi, res) {
+			break
+		}
+		if hi >= maxReverseIncome {
+			return ReverseCalculationResponse{}, errors.New("target can not be reached")
+		}
+		lo, hi = hi, min(hi*2, maxReverseIncome)
+	}
+
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		res, err := calculate(mid)
+		if err != nil {
+			return ReverseCalculationResponse{}, err
+		}
+		if reached(mid, res) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	res, err := calculate(hi)
+	if err != nil {
+		return ReverseCalculationResponse{}, err
+	}
+
+	return ReverseCalculationResponse{
+		TotalIncome:    hi,
+		AfterTaxIncome: hi - res.TaxBeforeWht,
+		Calculation:    res,
+	}, nil
+}
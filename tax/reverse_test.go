@@ -0,0 +1,63 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReverse(t *testing.T) {
+	personal := TaxDeduction{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}
+
+	tests := []struct {
+		name     string
+		rc       ReverseCalculation
+		expected Money
+	}{
+		{
+			// 20% of the last satang is truncated away, so 1,122,499.99
+			// already leaves 1,000,000 after tax.
+			name:     "target net income",
+			rc:       ReverseCalculation{TargetNetIncome: 1000000 * Baht},
+			expected: 1122500*Baht - 1,
+		},
+		{
+			name:     "target tax",
+			rc:       ReverseCalculation{TargetTax: 29000 * Baht},
+			expected: 500000 * Baht,
+		},
+		{
+			name:     "salary grossed up with expenses",
+			rc:       ReverseCalculation{TargetTax: 29000 * Baht, IncomeType: IncomeSalary},
+			expected: 600000 * Baht,
+		},
+		{
+			name:     "inside the exemption",
+			rc:       ReverseCalculation{TargetNetIncome: 100000 * Baht},
+			expected: 100000 * Baht,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := Reverse(test.rc, thaiTaxRates(), []TaxDeduction{personal})
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, res.TotalIncome)
+			assert.Equal(t, res.TotalIncome-res.Calculation.TaxBeforeWht, res.AfterTaxIncome)
+		})
+	}
+
+	t.Run("both targets", func(t *testing.T) {
+		_, err := Reverse(ReverseCalculation{TargetNetIncome: 1 * Baht, TargetTax: 1 * Baht}, thaiTaxRates(), nil)
+
+		assert.Equal(t, errors.New("either target net income or target tax is required"), err)
+	})
+
+	t.Run("tax that no income reaches", func(t *testing.T) {
+		_, err := Reverse(ReverseCalculation{TargetTax: 1 * Baht}, []TaxRate{{LowerBoundIncome: 0, TaxRate: 0}}, nil)
+
+		assert.Equal(t, errors.New("target can not be reached"), err)
+	})
+}
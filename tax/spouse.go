@@ -0,0 +1,88 @@
+package tax
+
+import "errors"
+
+// Filing options reported in FilingComparison.Recommended.
+const (
+	FilingJoint    = "joint"
+	FilingSeparate = "separate"
+)
+
+// fileSpouse works out both ways a married couple can file. Filing
+// separately, each spouse takes their own personal allowance and nobody can
+// claim the spouse allowance. Filing jointly, the taxpayer claims the spouse
+// allowance in place of the spouse's personal allowance and the two net
+// incomes are taxed together.
+func fileSpouse(tc TaxCalculation, own CalculationResponse, rates []TaxRate, tds []TaxDeduction) (*FilingComparison, error) {
+	spouse := TaxCalculation{
+		TotalIncome:    tc.Spouse.TotalIncome,
+		WithHoldingTax: tc.Spouse.WithHoldingTax,
+		Incomes:        tc.Spouse.Incomes,
+		Allowances:     tc.Spouse.Allowances,
+		Prepayments:    tc.Spouse.Prepayments,
+		TaxYear:        tc.TaxYear,
+		FilingType:     tc.FilingType,
+	}
+
+	separate, err := calculate(spouse, rates, tds)
+	if err != nil {
+		return nil, err
+	}
+
+	var withoutPersonal []TaxDeduction
+	for _, td := range tds {
+		if td.TaxAllowanceType != "personal" {
+			withoutPersonal = append(withoutPersonal, td)
+		}
+	}
+	spouseJoint, err := calculate(spouse, rates, withoutPersonal)
+	if err != nil {
+		return nil, err
+	}
+
+	taxpayer := tc
+	taxpayer.Spouse = nil
+	taxpayer.Allowances = append(append([]Allowance{}, tc.Allowances...), Allowance{AllowanceType: "spouse"})
+	ownJoint, err := calculate(taxpayer, rates, tds)
+	if err != nil {
+		return nil, err
+	}
+
+	bracketTax, _ := progressiveTax(ownJoint.NetIncome+spouseJoint.NetIncome, sortedRates(rates))
+	due := max(bracketTax, minimumTax(append(append([]Income{}, tc.Incomes...), spouse.Incomes...), tc.FilingType))
+	refund, payable := refundTax(due - taxCredits(ownJoint) - taxCredits(spouseJoint))
+
+	fc := &FilingComparison{
+		Joint: FilingOption{Tax: payable, TaxRefund: refund, TaxBeforeWht: due},
+		Separate: FilingOption{
+			Tax:          own.Tax + separate.Tax,
+			TaxRefund:    own.TaxRefund + separate.TaxRefund,
+			TaxBeforeWht: own.TaxBeforeWht + separate.TaxBeforeWht,
+		},
+		Recommended: FilingSeparate,
+	}
+	if fc.Joint.TaxBeforeWht < fc.Separate.TaxBeforeWht {
+		fc.Recommended = FilingJoint
+	}
+	return fc, nil
+}
+
+// taxCredits is everything res credited against its tax: withholding, tax
+// paid earlier in the year and tax credits.
+func taxCredits(res CalculationResponse) Money {
+	return res.TaxBeforeWht - res.Tax + res.TaxRefund
+}
+
+// checkSpouseClaim rejects a spouse allowance claimed alongside a spouse
+// block, since the block decides who gets it.
+func checkSpouseClaim(tc TaxCalculation) error {
+	if tc.Spouse == nil {
+		return nil
+	}
+	for _, a := range tc.Allowances {
+		if a.AllowanceType == "spouse" {
+			return errors.New("spouse allowance is worked out from the spouse block")
+		}
+	}
+	return nil
+}
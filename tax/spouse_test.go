@@ -0,0 +1,108 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/plakak13/assessment-tax/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpouseFiling(t *testing.T) {
+	tds := []TaxDeduction{
+		{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht},
+		{TaxAllowanceType: "spouse", MaxDeductionAmount: 60000 * Baht, PerUnit: true, MaxUnits: 1},
+		{TaxAllowanceType: "life-insurance", MaxDeductionAmount: 100000 * Baht},
+	}
+
+	t.Run("separate when both earn", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome: 1000000 * Baht,
+			Spouse: &Spouse{
+				TotalIncome:    200000 * Baht,
+				WithHoldingTax: 5000 * Baht,
+				Allowances:     []Allowance{{AllowanceType: "life-insurance", Amount: 20000 * Baht}},
+			},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		// Jointly: 880,000 + 180,000 is taxed at 20% on the top 60,000.
+		assert.NoError(t, err)
+		assert.Equal(t, 101000*Baht, res.Tax)
+		assert.Equal(t, &FilingComparison{
+			Joint:       FilingOption{Tax: 117000 * Baht, TaxBeforeWht: 122000 * Baht},
+			Separate:    FilingOption{Tax: 101000 * Baht, TaxRefund: 5000 * Baht, TaxBeforeWht: 101000 * Baht},
+			Recommended: FilingSeparate,
+		}, res.Filing)
+	})
+
+	t.Run("spouse prepayments from several payers", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome: 1000000 * Baht,
+			Spouse: &Spouse{
+				TotalIncome: 200000 * Baht,
+				Allowances:  []Allowance{{AllowanceType: "life-insurance", Amount: 20000 * Baht}},
+				Prepayments: []Prepayment{
+					{Source: PrepaymentWht, PayerTaxID: "0105536112014", Amount: 3000 * Baht},
+					{Source: PrepaymentWht, PayerTaxID: "0107537000254", Amount: 2000 * Baht},
+				},
+			},
+		}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &FilingComparison{
+			Joint:       FilingOption{Tax: 117000 * Baht, TaxBeforeWht: 122000 * Baht},
+			Separate:    FilingOption{Tax: 101000 * Baht, TaxRefund: 5000 * Baht, TaxBeforeWht: 101000 * Baht},
+			Recommended: FilingSeparate,
+		}, res.Filing)
+	})
+
+	t.Run("spouse prepayments are checked", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome: 1000000 * Baht,
+			Allowances:  []Allowance{},
+			Spouse: &Spouse{
+				TotalIncome:    200000 * Baht,
+				WithHoldingTax: 1000 * Baht,
+				Prepayments:    []Prepayment{{Source: PrepaymentWht, PayerTaxID: "0105536112014", Amount: 3000 * Baht}},
+			},
+		}
+
+		_, err := Calculate(tc, thaiTaxRates(), tds)
+		assert.Equal(t, errors.New("wht is deprecated, send it as a wht prepayment with its payer tax id"), err)
+
+		tc.Spouse.WithHoldingTax = 0
+		tc.Spouse.Prepayments[0].PayerTaxID = "0105536112015"
+		_, err = Calculate(tc, thaiTaxRates(), tds)
+		assert.Equal(t, errors.New("invalid payer tax id 0105536112015"), err)
+
+		tc.Spouse.Prepayments[0].PayerTaxID = ""
+		assert.Error(t, helper.NewValidator().Validate(&tc))
+	})
+
+	t.Run("joint when the spouse has no income", func(t *testing.T) {
+		tc := TaxCalculation{TotalIncome: 1000000 * Baht, Spouse: &Spouse{}}
+
+		res, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 92000*Baht, res.Filing.Joint.TaxBeforeWht)
+		assert.Equal(t, 101000*Baht, res.Filing.Separate.TaxBeforeWht)
+		assert.Equal(t, FilingJoint, res.Filing.Recommended)
+	})
+
+	t.Run("spouse allowance claimed with a spouse block", func(t *testing.T) {
+		tc := TaxCalculation{
+			TotalIncome: 1000000 * Baht,
+			Allowances:  []Allowance{{AllowanceType: "spouse"}},
+			Spouse:      &Spouse{},
+		}
+
+		_, err := Calculate(tc, thaiTaxRates(), tds)
+
+		assert.Equal(t, errors.New("spouse allowance is worked out from the spouse block"), err)
+	})
+}
@@ -1,47 +1,314 @@
 package tax
 
 type Allowance struct {
-	AllowanceType string  `json:"allowanceType" example:"donation"`
-	Amount        float64 `json:"amount" example:"100.00"`
+	AllowanceType string `json:"allowanceType" example:"donation"`
+	Amount        Money  `json:"amount" example:"100.00"`
+	Count         int    `json:"count,omitempty" example:"2"`
 }
 
+type Income struct {
+	IncomeType    string `json:"incomeType" example:"salary"`
+	Amount        Money  `json:"amount" example:"600000.00"`
+	AssetType     string `json:"assetType,omitempty" example:"building"`
+	Profession    string `json:"profession,omitempty" example:"medical"`
+	ExpenseMethod string `json:"expenseMethod,omitempty" example:"flat"`
+	ActualExpense Money  `json:"actualExpense,omitempty" example:"0.00"`
+}
+
+// TaxCalculation is one taxpayer's return. WithHoldingTax is deprecated in
+// favour of wht Prepayments, which carry the payer, and can not be sent with
+// them.
 type TaxCalculation struct {
-	TotalIncome    float64     `json:"totalIncome" validate:"required" example:"1000.00"`
-	WithHoldingTax float64     `json:"wht" example:"0.0"`
+	TotalIncome    Money       `json:"totalIncome" validate:"required_without=Incomes" example:"1000.00"`
+	WithHoldingTax Money       `json:"wht" example:"0.0"`
 	Allowances     []Allowance `json:"allowances" validate:"required"`
+	TaxYear        int         `json:"taxYear" validate:"omitempty,gte=2500" example:"2567"`
+	Incomes        []Income    `json:"incomes,omitempty"`
+	Spouse         *Spouse     `json:"spouse,omitempty"`
+
+	FilingType  string       `json:"filingType,omitempty" validate:"omitempty,oneof=annual half-year" example:"annual"`
+	Prepayments []Prepayment `json:"prepayments,omitempty" validate:"dive"`
+
+	LateFiling *LateFiling `json:"lateFiling,omitempty"`
+
+	Dividends        []Dividend `json:"dividends,omitempty"`
+	IncludeDividends bool       `json:"includeDividends" example:"false"`
+
+	ForeignIncomes []ForeignIncome `json:"foreignIncomes,omitempty" validate:"dive"`
+}
+
+type ForeignIncome struct {
+	Country        string `json:"country" validate:"required" example:"US"`
+	Amount         Money  `json:"amount" example:"500000.00"`
+	ForeignTaxPaid Money  `json:"foreignTaxPaid" example:"50000.00"`
+}
+
+type Prepayment struct {
+	Source     string `json:"source" validate:"required,oneof=wht half-year" example:"wht"`
+	PayerTaxID string `json:"payerTaxId" validate:"required" example:"0105536112014"`
+	Amount     Money  `json:"amount" example:"12000.00"`
+}
+
+type Dividend struct {
+	Amount           Money  `json:"amount" example:"90000.00"`
+	Withheld         Money  `json:"withheld" example:"9000.00"`
+	CorporateTaxRate Rate   `json:"corporateTaxRate" example:"20.00"`
+	PayerTaxID       string `json:"payerTaxId,omitempty" example:"0107537000254"`
+}
+
+type LateFiling struct {
+	DueDate     string `json:"dueDate" validate:"required,datetime=2006-01-02" example:"2025-03-31"`
+	PaymentDate string `json:"paymentDate" validate:"required,datetime=2006-01-02" example:"2025-05-15"`
+	Penalty     Money  `json:"penalty" example:"200.00"`
+}
+
+// Spouse is the spouse's side of a return. As for the taxpayer,
+// WithHoldingTax is deprecated in favour of Prepayments.
+type Spouse struct {
+	TotalIncome    Money        `json:"totalIncome" example:"300000.00"`
+	WithHoldingTax Money        `json:"wht" example:"0.0"`
+	Incomes        []Income     `json:"incomes,omitempty"`
+	Allowances     []Allowance  `json:"allowances"`
+	Prepayments    []Prepayment `json:"prepayments,omitempty" validate:"dive"`
+}
+
+type ReverseCalculation struct {
+	TargetNetIncome Money       `json:"targetNetIncome" example:"1000000.00"`
+	TargetTax       Money       `json:"targetTax" example:"0.0"`
+	IncomeType      string      `json:"incomeType,omitempty" example:"salary"`
+	Allowances      []Allowance `json:"allowances"`
+	TaxYear         int         `json:"taxYear" validate:"omitempty,gte=2500" example:"2567"`
+}
+
+type ReverseCalculationResponse struct {
+	TotalIncome    Money               `json:"totalIncome" example:"1150000.00"`
+	AfterTaxIncome Money               `json:"afterTaxIncome" example:"1000000.00"`
+	Calculation    CalculationResponse `json:"calculation"`
+}
+
+type WithholdingCalculation struct {
+	MonthlySalary  Money       `json:"monthlySalary" validate:"required" example:"50000.00"`
+	MonthsEmployed int         `json:"monthsEmployed" validate:"required,gte=1,lte=12" example:"12"`
+	MonthsPaid     int         `json:"monthsPaid" validate:"gte=0,lte=11" example:"3"`
+	YTDIncome      Money       `json:"ytdIncome" example:"150000.00"`
+	YTDWithholding Money       `json:"ytdWithholding" example:"3375.00"`
+	Allowances     []Allowance `json:"allowances"`
+	TaxYear        int         `json:"taxYear" validate:"omitempty,gte=2500" example:"2567"`
+}
+
+type WithholdingResponse struct {
+	AnnualIncome    Money `json:"annualIncome" example:"600000.00"`
+	AnnualTax       Money `json:"annualTax" example:"13500.00"`
+	RemainingMonths int   `json:"remainingMonths" example:"9"`
+	Withholding     Money `json:"withholding" example:"1125.00"`
+}
+
+type OptimizationRequest struct {
+	TaxCalculation
+	Budget Money `json:"budget" example:"100000.00"`
+}
+
+type OptimizationResponse struct {
+	Tax          Money        `json:"tax" example:"29000.00"`
+	Budget       Money        `json:"budget" example:"100000.00"`
+	Suggestions  []Suggestion `json:"suggestions"`
+	Plan         []Suggestion `json:"plan"`
+	PlanTaxSaved Money        `json:"planTaxSaved" example:"10000.00"`
+}
+
+type Suggestion struct {
+	AllowanceType    string `json:"allowanceType" example:"rmf"`
+	AdditionalAmount Money  `json:"additionalAmount" example:"100000.00"`
+	TaxSaved         Money  `json:"taxSaved" example:"10000.00"`
+}
+
+type ComparisonRequest struct {
+	Baseline  string     `json:"baseline" example:"current"`
+	TaxYear   int        `json:"taxYear" validate:"omitempty,gte=2500" example:"2567"`
+	Scenarios []Scenario `json:"scenarios" validate:"required,min=1,max=10,dive"`
+}
+
+type Scenario struct {
+	Name        string         `json:"name" validate:"required" example:"current"`
+	Calculation TaxCalculation `json:"calculation"`
+}
+
+type ComparisonResponse struct {
+	Baseline  string           `json:"baseline" example:"current"`
+	Scenarios []ScenarioResult `json:"scenarios"`
+}
+
+type ScenarioResult struct {
+	Name   string              `json:"name" example:"more insurance"`
+	Result CalculationResponse `json:"result"`
+	Diff   ScenarioDiff        `json:"diff"`
+}
+
+type ScenarioDiff struct {
+	Tax       Money          `json:"tax" example:"-1500.00"`
+	TaxRefund Money          `json:"taxRefund" example:"0.00"`
+	TaxLevel  []TaxLevelInfo `json:"taxLevel"`
+}
+
+// Snapshot is the rate and deduction tables of one year as read at one
+// moment.
+type Snapshot struct {
+	Rates      []TaxRate
+	Deductions []TaxDeduction
 }
 
 type TaxDeduction struct {
-	ID                 int     `json:"id" example:"1"`
-	MaxDeductionAmount float64 `json:"max_deduction_amount" example:"100.00"`
-	DefaultAmount      float64 `json:"default_amount" example:"100.00"`
-	AdminOverrideMax   float64 `json:"admin_override_max" example:"100.00"`
-	MinAmount          float64 `json:"min_amount" example:"100.00"`
-	TaxAllowanceType   string  `json:"tax_allowance_type" example:"donation"`
+	ID                 int    `json:"id" example:"1"`
+	MaxDeductionAmount Money  `json:"max_deduction_amount" example:"100.00"`
+	DefaultAmount      Money  `json:"default_amount" example:"100.00"`
+	AdminOverrideMax   Money  `json:"admin_override_max" example:"100.00"`
+	MinAmount          Money  `json:"min_amount" example:"100.00"`
+	TaxAllowanceType   string `json:"tax_allowance_type" example:"donation"`
+	TaxYear            int    `json:"tax_year" example:"2567"`
+	MaxPercentOfIncome Rate   `json:"max_percent_of_income" example:"15.00"`
+	PerUnit            bool   `json:"per_unit" example:"false"`
+	MaxUnits           int    `json:"max_units" example:"4"`
+	DeductionGroup     string `json:"deduction_group" example:"retirement"`
+	GroupMaxAmount     Money  `json:"group_max_amount" example:"500000.00"`
+	AfterDeductions    bool   `json:"after_deductions" example:"true"`
+	DeductionRate      Rate   `json:"deduction_rate" example:"200.00"`
+
+	GroupMaxPercentOfIncome Rate `json:"group_max_percent_of_income" example:"0.00"`
+	HalveForHalfYear        bool `json:"halve_for_half_year" example:"false"`
 }
 
 type TaxRate struct {
-	ID               int     `json:"id" example:"1"`
-	LowerBoundIncome float64 `json:"lower_bound_income" example:"100.0"`
-	TaxRate          float64 `json:"tax_rate" example:"100.0"`
+	ID               int   `json:"id" example:"1"`
+	LowerBoundIncome Money `json:"lower_bound_income" example:"100.0"`
+	TaxRate          Rate  `json:"tax_rate" example:"100.0"`
+	TaxYear          int   `json:"tax_year" example:"2567"`
 }
 
 type CalculationResponse struct {
-	Tax       float64        `json:"tax" example:"100.0"`
-	TaxRefund float64        `json:"taxRefund" example:"1000.0"`
+	Tax       Money          `json:"tax" example:"100.0"`
+	TaxRefund Money          `json:"taxRefund" example:"1000.0"`
 	TaxLevel  []TaxLevelInfo `json:"taxLevel" example:"taxAllowance"`
+
+	NetIncome       Money `json:"netIncome" example:"440000.00"`
+	TotalDeductions Money `json:"totalDeductions" example:"60000.00"`
+	TaxBeforeWht    Money `json:"taxBeforeWht" example:"29000.00"`
+	EffectiveRate   Rate  `json:"effectiveRate" example:"5.80"`
+	MarginalRate    Rate  `json:"marginalRate" example:"10.00"`
+
+	BracketTax Money  `json:"bracketTax" example:"100.0"`
+	MinimumTax Money  `json:"minimumTax" example:"0.0"`
+	TaxMethod  string `json:"taxMethod" example:"bracket"`
+
+	Deductions []DeductionDetail `json:"deductions"`
+	Expenses   []IncomeExpense   `json:"expenses,omitempty"`
+
+	Explanation []ExplanationStep `json:"explanation,omitempty"`
+
+	Filing *FilingComparison `json:"filing,omitempty"`
+
+	LateCharges *LateCharges `json:"lateCharges,omitempty"`
+
+	Dividends *DividendComparison `json:"dividends,omitempty"`
+
+	ForeignTaxCredits []ForeignTaxCredit `json:"foreignTaxCredits,omitempty"`
+
+	Prepayments []PrepaymentTotal `json:"prepayments,omitempty"`
+}
+
+type PrepaymentTotal struct {
+	Source string `json:"source" example:"wht"`
+	Amount Money  `json:"amount" example:"12000.00"`
+}
+
+type ForeignTaxCredit struct {
+	Country        string `json:"country" example:"US"`
+	ForeignTaxPaid Money  `json:"foreignTaxPaid" example:"50000.00"`
+	Credit         Money  `json:"credit" example:"42000.00"`
+}
+
+type DividendComparison struct {
+	Included         FilingOption `json:"included"`
+	FinalWithholding FilingOption `json:"finalWithholding"`
+	Credit           Money        `json:"credit" example:"22500.00"`
+	Recommended      string       `json:"recommended" example:"included"`
+}
+
+type LateCharges struct {
+	MonthsLate int   `json:"monthsLate" example:"2"`
+	Surcharge  Money `json:"surcharge" example:"435.00"`
+	Penalty    Money `json:"penalty" example:"200.00"`
+	TotalDue   Money `json:"totalDue" example:"15135.00"`
+}
+
+type FilingComparison struct {
+	Joint       FilingOption `json:"joint"`
+	Separate    FilingOption `json:"separate"`
+	Recommended string       `json:"recommended" example:"separate"`
+}
+
+type FilingOption struct {
+	Tax          Money `json:"tax" example:"0.0"`
+	TaxRefund    Money `json:"taxRefund" example:"0.0"`
+	TaxBeforeWht Money `json:"taxBeforeWht" example:"29000.00"`
+}
+
+type ExplanationStep struct {
+	Step      string `json:"step" example:"allowance"`
+	LabelTH   string `json:"labelTh" example:"ค่าลดหย่อน donation"`
+	LabelEN   string `json:"labelEn" example:"Allowance donation"`
+	Amount    Money  `json:"amount" example:"100000.00"`
+	Requested Money  `json:"requested,omitempty" example:"200000.00"`
+	Rate      Rate   `json:"rate,omitempty" example:"10.00"`
+	Cap       Money  `json:"cap,omitempty" example:"100000.00"`
+	CapRule   string `json:"capRule,omitempty" example:"max_deduction_amount"`
+	ClampedBy string `json:"clampedBy,omitempty" example:"max_deduction_amount"`
+}
+
+type IncomeExpense struct {
+	IncomeType string `json:"incomeType" example:"salary"`
+	Section    string `json:"section" example:"40(1)"`
+	Income     Money  `json:"income" example:"600000.00"`
+	Expense    Money  `json:"expense" example:"100000.00"`
+}
+
+type DeductionDetail struct {
+	AllowanceType string `json:"allowanceType" example:"rmf"`
+	Requested     Money  `json:"requested" example:"600000.00"`
+	Allowed       Money  `json:"allowed" example:"500000.00"`
+	Clamped       Money  `json:"clamped" example:"100000.00"`
+	Cap           Money  `json:"cap" example:"500000.00"`
+	CapRule       string `json:"capRule" example:"group_max_amount"`
+	ClampedBy     string `json:"clampedBy,omitempty" example:"group_max_amount"`
+	Group         string `json:"group,omitempty" example:"retirement"`
 }
 
 type TaxLevelInfo struct {
-	Level string  `json:"level" example:"0-150,000"`
-	Tax   float64 `json:"tax" example:"100.0"`
+	Level string `json:"level" example:"0-150,000"`
+	Tax   Money  `json:"tax" example:"100.0"`
 }
 
 type TaxCSVCalculation struct {
-	Taxes []TaxWithTotalIncome
+	Taxes   []TaxWithTotalIncome `json:"taxes"`
+	Errors  []CSVRowError        `json:"errors"`
+	Summary CSVSummary           `json:"summary"`
+}
+
+type CSVRowError struct {
+	Row        int    `json:"row" example:"3"`
+	EmployeeID string `json:"employeeId,omitempty" example:"E0002"`
+	Message    string `json:"message" example:"donation can not be string or empty"`
+}
+
+type CSVSummary struct {
+	RowsOK     int `json:"rowsOk" example:"19999"`
+	RowsFailed int `json:"rowsFailed" example:"1"`
 }
 
 type TaxWithTotalIncome struct {
-	TotalIncome float64 `json:"totalIncome" example:"100.0"`
-	TaxAmount   float64 `json:"tax" example:"100.0"`
+	Row         int    `json:"row" example:"2"`
+	EmployeeID  string `json:"employeeId,omitempty" example:"E0001"`
+	Name        string `json:"name,omitempty" example:"Somchai"`
+	TotalIncome Money  `json:"totalIncome" example:"100.0"`
+	TaxAmount   Money  `json:"tax" example:"100.0"`
+	TaxRefund   Money  `json:"taxRefund" example:"0.0"`
 }
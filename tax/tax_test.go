@@ -2,13 +2,20 @@ package tax
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/plakak13/assessment-tax/helper"
@@ -22,13 +29,23 @@ type MockTax struct {
 	errorTaxRate      error
 }
 
-func (h MockTax) TaxDeductionByType(allowanceTypes []string) ([]TaxDeduction, error) {
+func (h MockTax) TaxDeductionByType(taxYear int, allowanceTypes []string) ([]TaxDeduction, error) {
 	if h.errorTaxDeduction != nil {
 		return nil, h.errorTaxDeduction
 	}
 	return h.taxDeductions, nil
 }
 
+func (h MockTax) TaxSnapshot(taxYear int, allowanceTypes []string) (Snapshot, error) {
+	if h.errorTaxDeduction != nil {
+		return Snapshot{}, h.errorTaxDeduction
+	}
+	if h.errorTaxRate != nil {
+		return Snapshot{}, h.errorTaxRate
+	}
+	return Snapshot{Rates: h.taxRates, Deductions: h.taxDeductions}, nil
+}
+
 func (h MockTax) CalculationCSV() error {
 	if h.errorTaxRate != nil {
 		return h.errorTaxRate
@@ -37,7 +54,7 @@ func (h MockTax) CalculationCSV() error {
 	return nil
 }
 
-func (h MockTax) TaxRates() ([]TaxRate, error) {
+func (h MockTax) TaxRates(taxYear int) ([]TaxRate, error) {
 	if h.errorTaxRate != nil {
 		return nil, h.errorTaxRate
 	}
@@ -69,13 +86,13 @@ func TestCalculationHandler_Success(t *testing.T) {
 			},
 			{
 				ID:               2,
-				LowerBoundIncome: 150001.0,
-				TaxRate:          10,
+				LowerBoundIncome: 150001 * Baht,
+				TaxRate:          10 * Percent,
 			},
 			{
 				ID:               3,
-				LowerBoundIncome: 500001.0,
-				TaxRate:          15,
+				LowerBoundIncome: 500001 * Baht,
+				TaxRate:          15 * Percent,
 			},
 		},
 	})
@@ -86,6 +103,212 @@ func TestCalculationHandler_Success(t *testing.T) {
 
 }
 
+func TestCalculationHandler_Explain(t *testing.T) {
+	body := `{"totalIncome": 500000.0, "wht": 25000.0, "allowances": [{"allowanceType": "donation", "amount": 200000.0}]}`
+	h := New(MockTax{
+		taxRates: []TaxRate{
+			{ID: 1, LowerBoundIncome: 0, TaxRate: 0},
+			{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+		},
+		taxDeductions: []TaxDeduction{
+			{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht},
+			{TaxAllowanceType: "donation", MaxDeductionAmount: 100000 * Baht},
+		},
+	})
+
+	for _, target := range []string{"/tax/calculations?explain=true", "/tax/calculations"} {
+		t.Run(target, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = helper.NewValidator()
+			req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.CalculationHandler(c)
+
+			var res CalculationResponse
+			assert.NoError(t, err)
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			if target == "/tax/calculations" {
+				assert.Empty(t, res.Explanation)
+				return
+			}
+			assert.Equal(t, []ExplanationStep{
+				{Step: "gross_income", LabelTH: "เงินได้พึงประเมิน", LabelEN: "Gross income", Amount: 500000 * Baht},
+				{Step: "allowance", LabelTH: "ค่าลดหย่อน personal", LabelEN: "Allowance personal", Amount: 60000 * Baht, Requested: 60000 * Baht, Cap: 60000 * Baht, CapRule: "max_deduction_amount"},
+				{Step: "allowance", LabelTH: "ค่าลดหย่อน donation", LabelEN: "Allowance donation", Amount: 100000 * Baht, Requested: 200000 * Baht, Cap: 100000 * Baht, CapRule: "max_deduction_amount", ClampedBy: "max_deduction_amount"},
+				{Step: "net_income", LabelTH: "เงินได้สุทธิ", LabelEN: "Net income", Amount: 340000 * Baht},
+				{Step: "bracket", LabelTH: "ภาษีขั้น 0-150,000", LabelEN: "Tax for 0-150,000", Amount: 0},
+				{Step: "bracket", LabelTH: "ภาษีขั้น 150,001 ขึ้นไป", LabelEN: "Tax for 150,001 ขึ้นไป", Amount: 19000 * Baht, Rate: 10 * Percent},
+				{Step: "bracket_tax", LabelTH: "ภาษีตามขั้นบันได", LabelEN: "Tax by brackets", Amount: 19000 * Baht},
+				{Step: "tax_due", LabelTH: "ภาษีที่ต้องเสีย", LabelEN: "Tax due", Amount: 19000 * Baht},
+				{Step: "wht", LabelTH: "หักภาษี ณ ที่จ่าย", LabelEN: "Withholding tax", Amount: 25000 * Baht},
+				{Step: "tax_payable", LabelTH: "ภาษีที่ต้องชำระเพิ่ม", LabelEN: "Tax payable", Amount: 0},
+				{Step: "tax_refund", LabelTH: "ภาษีที่ได้รับคืน", LabelEN: "Tax refund", Amount: 6000 * Baht},
+			}, res.Explanation)
+		})
+	}
+}
+
+func TestReverseCalculationHandler(t *testing.T) {
+	h := New(MockTax{
+		taxRates: []TaxRate{
+			{ID: 1, LowerBoundIncome: 0, TaxRate: 0},
+			{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+		},
+		taxDeductions: []TaxDeduction{{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}},
+	})
+
+	t.Run("solves the income", func(t *testing.T) {
+		e := echo.New()
+		e.Validator = helper.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/reverse", strings.NewReader(`{"targetTax": 29000.0, "allowances": []}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.ReverseCalculationHandler(c)
+
+		var res ReverseCalculationResponse
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, 500000*Baht, res.TotalIncome)
+		assert.Equal(t, 471000*Baht, res.AfterTaxIncome)
+	})
+
+	t.Run("no target", func(t *testing.T) {
+		e := echo.New()
+		e.Validator = helper.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/reverse", strings.NewReader(`{"allowances": []}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.ReverseCalculationHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestWithholdingHandler(t *testing.T) {
+	h := New(MockTax{
+		taxRates: []TaxRate{
+			{ID: 1, LowerBoundIncome: 0, TaxRate: 0},
+			{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+		},
+		taxDeductions: []TaxDeduction{{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}},
+	})
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{name: "this month's withholding", body: `{"monthlySalary": 50000.0, "monthsEmployed": 12}`, expectedCode: http.StatusOK},
+		{name: "months employed is required", body: `{"monthlySalary": 50000.0}`, expectedCode: http.StatusBadRequest},
+		{name: "every month already paid", body: `{"monthlySalary": 50000.0, "monthsEmployed": 3, "monthsPaid": 3}`, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = helper.NewValidator()
+			req := httptest.NewRequest(http.MethodPost, "/tax/withholdings", strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.WithholdingHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestOptimizationHandler(t *testing.T) {
+	e := echo.New()
+	e.Validator = helper.NewValidator()
+	body := `{"totalIncome": 500000.0, "allowances": [], "budget": 10000.0}`
+	req := httptest.NewRequest(http.MethodPost, "/tax/optimizations", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	h := New(MockTax{
+		taxRates: []TaxRate{
+			{ID: 1, LowerBoundIncome: 0, TaxRate: 0},
+			{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+		},
+		taxDeductions: []TaxDeduction{
+			{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht},
+			{TaxAllowanceType: "k-receipt", MaxDeductionAmount: 50000 * Baht},
+		},
+	})
+	err := h.OptimizationHandler(c)
+
+	var res OptimizationResponse
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, []Suggestion{{AllowanceType: "k-receipt", AdditionalAmount: 50000 * Baht, TaxSaved: 5000 * Baht}}, res.Suggestions)
+	assert.Equal(t, []Suggestion{{AllowanceType: "k-receipt", AdditionalAmount: 10000 * Baht, TaxSaved: 1000 * Baht}}, res.Plan)
+}
+
+func TestCompareHandler(t *testing.T) {
+	mock := MockTax{
+		taxRates:      []TaxRate{{ID: 1, LowerBoundIncome: 0, TaxRate: 0}, {ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent}},
+		taxDeductions: []TaxDeduction{{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}},
+	}
+	failing := mock
+	failing.errorTaxDeduction = errors.New("snapshot failed")
+
+	tests := []struct {
+		name         string
+		store        MockTax
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "compares scenarios",
+			store:        mock,
+			body:         `{"scenarios": [{"name": "a", "calculation": {"totalIncome": 500000.0, "allowances": []}}, {"name": "b", "calculation": {"totalIncome": 600000.0, "allowances": []}}]}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "scenario without income",
+			store:        mock,
+			body:         `{"scenarios": [{"name": "a", "calculation": {"allowances": []}}]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "snapshot failed",
+			store:        failing,
+			body:         `{"scenarios": [{"name": "a", "calculation": {"totalIncome": 500000.0, "allowances": []}}]}`,
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = helper.NewValidator()
+			req := httptest.NewRequest(http.MethodPost, "/tax/calculations/compare", strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := New(test.store).CompareHandler(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCode, rec.Code)
+		})
+	}
+}
+
 func TestCalculationHandler_BadRequest(t *testing.T) {
 	t.Run("tax with holding is 0 should retrun bad request", func(t *testing.T) {
 		e := echo.New()
@@ -112,13 +335,13 @@ func TestCalculationHandler_BadRequest(t *testing.T) {
 				},
 				{
 					ID:               2,
-					LowerBoundIncome: 150001.0,
-					TaxRate:          10,
+					LowerBoundIncome: 150001 * Baht,
+					TaxRate:          10 * Percent,
 				},
 				{
 					ID:               3,
-					LowerBoundIncome: 500001.0,
-					TaxRate:          15,
+					LowerBoundIncome: 500001 * Baht,
+					TaxRate:          15 * Percent,
 				},
 			},
 		})
@@ -168,7 +391,7 @@ func TestCalculationHandler_BadRequest(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Equal(t, "Field TotalIncome is required, Field Allowances is required", jsonMashal(rec.Body.Bytes()).Message)
+		assert.Equal(t, "Field TotalIncome is required_without Incomes, Field Allowances is required", jsonMashal(rec.Body.Bytes()).Message)
 	})
 
 	t.Run("worng json body should retrun bad request", func(t *testing.T) {
@@ -242,83 +465,6 @@ func TestCalculationHandler_BadRequest(t *testing.T) {
 	})
 }
 
-func TestCalculatePayable(t *testing.T) {
-	type test struct {
-		name          string
-		expected      float64
-		totalIncome   float64
-		wht           float64
-		taxDeductions []TaxDeduction
-		taxRate       TaxRate
-	}
-	tests := []test{
-		{
-			name:        "with donation",
-			expected:    29000.0,
-			totalIncome: 440000.0,
-			wht:         0.0,
-			taxDeductions: []TaxDeduction{
-				{
-					MaxDeductionAmount: 100000.0,
-					DefaultAmount:      0.0,
-					AdminOverrideMax:   0.0,
-					MinAmount:          0.0,
-					TaxAllowanceType:   "donation",
-				},
-			},
-			taxRate: TaxRate{
-				LowerBoundIncome: 150001,
-				TaxRate:          10,
-			},
-		},
-		{
-			name:        "with k-reciept",
-			expected:    4000.0,
-			totalIncome: 440000.0,
-			wht:         25000.0,
-			taxDeductions: []TaxDeduction{
-				{
-					MaxDeductionAmount: 100000.0,
-					DefaultAmount:      50000.0,
-					AdminOverrideMax:   50000.0,
-					MinAmount:          0.00,
-					TaxAllowanceType:   "k-reciept",
-				},
-			},
-			taxRate: TaxRate{
-				LowerBoundIncome: 150001,
-				TaxRate:          10,
-			},
-		},
-		{
-			name:        "withholding tax 0 and total income not exceeding the tax threshold",
-			expected:    0.0,
-			totalIncome: -10000.0,
-			wht:         0.0,
-			taxDeductions: []TaxDeduction{
-				{
-					MaxDeductionAmount: 100000.0,
-					DefaultAmount:      50000.0,
-					AdminOverrideMax:   50000.0,
-					MinAmount:          0.00,
-					TaxAllowanceType:   "k-reciept",
-				},
-			},
-			taxRate: TaxRate{
-				LowerBoundIncome: 0,
-				TaxRate:          0,
-			},
-		},
-	}
-
-	for _, val := range tests {
-		t.Run(val.name, func(t *testing.T) {
-			got := calculateTaxPayable(val.totalIncome, val.wht, val.taxRate)
-			assert.Equal(t, val.expected, got)
-		})
-	}
-}
-
 func TestValidationTax(t *testing.T) {
 
 	tests := []struct {
@@ -330,47 +476,57 @@ func TestValidationTax(t *testing.T) {
 		{
 			name: "Invalid withholding tax - negative",
 			taxDeducts: []TaxDeduction{
-				{TaxAllowanceType: "personal", MinAmount: 10000},
+				{TaxAllowanceType: "personal", MinAmount: 10000 * Baht},
 			},
 			taxCalculation: TaxCalculation{
-				WithHoldingTax: -100,
-				TotalIncome:    100000,
+				WithHoldingTax: -100 * Baht,
+				TotalIncome:    100000 * Baht,
 			},
 			expectedError: errors.New("invalid withholding tax amount"),
 		},
 		{
 			name: "Invalid withholding tax - exceeds total income",
 			taxDeducts: []TaxDeduction{
-				{TaxAllowanceType: "personal", MinAmount: 10000},
+				{TaxAllowanceType: "personal", MinAmount: 10000 * Baht},
 			},
 			taxCalculation: TaxCalculation{
-				WithHoldingTax: 150000,
-				TotalIncome:    100000,
+				WithHoldingTax: 150000 * Baht,
+				TotalIncome:    100000 * Baht,
 			},
 			expectedError: errors.New("invalid withholding tax amount"),
 		},
 		{
 			name: "Amount for allowance is below the minimum threshold",
 			taxDeducts: []TaxDeduction{
-				{TaxAllowanceType: "personal", MinAmount: 10000},
+				{TaxAllowanceType: "personal", MinAmount: 10000 * Baht},
 			},
 			taxCalculation: TaxCalculation{
-				WithHoldingTax: 25000,
-				TotalIncome:    500000,
+				WithHoldingTax: 25000 * Baht,
+				TotalIncome:    500000 * Baht,
 				Allowances: []Allowance{
-					{AllowanceType: "personal", Amount: 500},
+					{AllowanceType: "personal", Amount: 500 * Baht},
 				},
 			},
 			expectedError: errors.New("amount for personal allowance is below the minimum threshold"),
 		},
+		{
+			name: "Negative allowance count",
+			taxCalculation: TaxCalculation{
+				TotalIncome: 500000 * Baht,
+				Allowances: []Allowance{
+					{AllowanceType: "child", Count: -1},
+				},
+			},
+			expectedError: errors.New("count for child allowance can not be negative"),
+		},
 		{
 			name: "No errors",
 			taxDeducts: []TaxDeduction{
-				{TaxAllowanceType: "personal", MinAmount: 10000},
+				{TaxAllowanceType: "personal", MinAmount: 10000 * Baht},
 			},
 			taxCalculation: TaxCalculation{
-				WithHoldingTax: 200,
-				TotalIncome:    1000,
+				WithHoldingTax: 200 * Baht,
+				TotalIncome:    1000 * Baht,
 			},
 			expectedError: nil,
 		},
@@ -384,71 +540,6 @@ func TestValidationTax(t *testing.T) {
 	}
 }
 
-func TestMaxDeduct(t *testing.T) {
-	tests := []struct {
-		name           string
-		tds            []TaxDeduction
-		alls           []Allowance
-		expectedDeduct float64
-	}{
-		{
-			name: "Personal allowance only",
-			tds: []TaxDeduction{
-				{TaxAllowanceType: "personal", MaxDeductionAmount: 60000},
-			},
-			alls:           nil,
-			expectedDeduct: 60000,
-		},
-		{
-			name: "Allowance lower than max deduction",
-			tds: []TaxDeduction{
-				{TaxAllowanceType: "personal", MaxDeductionAmount: 60000},
-				{TaxAllowanceType: "donation", MaxDeductionAmount: 100000},
-			},
-			alls: []Allowance{
-				{AllowanceType: "donation", Amount: 40000},
-			},
-			expectedDeduct: 100000,
-		},
-		{
-			name: "Allowance higher than max deduction",
-			tds: []TaxDeduction{
-				{TaxAllowanceType: "personal", MaxDeductionAmount: 60000},
-				{TaxAllowanceType: "donation", MaxDeductionAmount: 100000},
-			},
-			alls: []Allowance{
-				{AllowanceType: "donation", Amount: 200000},
-			},
-			expectedDeduct: 160000,
-		},
-		{
-			name: "personal allowance",
-			tds: []TaxDeduction{
-				{TaxAllowanceType: "personal", MaxDeductionAmount: 60000},
-				{TaxAllowanceType: "donation", MaxDeductionAmount: 100000},
-			},
-			alls: []Allowance{
-				{AllowanceType: "personal", Amount: 200000},
-			},
-			expectedDeduct: 60000,
-		},
-		{
-			name:           "No allowances",
-			tds:            nil,
-			alls:           nil,
-			expectedDeduct: 0,
-		},
-	}
-
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			result := maxDeduct(test.tds, test.alls)
-			assert.Equal(t, test.expectedDeduct, result)
-		})
-	}
-
-}
-
 func TestCalculationCSV_Success(t *testing.T) {
 
 	e := echo.New()
@@ -457,7 +548,7 @@ func TestCalculationCSV_Success(t *testing.T) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 	part, _ := writer.CreateFormFile("file", "test.csv")
-	part.Write([]byte("totalIncome,wht,donation\n10000,1000,500"))
+	part.Write([]byte("totalIncome,wht,donation\n10000,1000,500\n200000,0,abc\n300\"000,0,0\n500000,0,0"))
 	writer.Close()
 
 	req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
@@ -474,39 +565,194 @@ func TestCalculationCSV_Success(t *testing.T) {
 			},
 			{
 				ID:               2,
-				LowerBoundIncome: 150001.0,
-				TaxRate:          10,
+				LowerBoundIncome: 150001 * Baht,
+				TaxRate:          10 * Percent,
 			},
 			{
 				ID:               3,
-				LowerBoundIncome: 500001.0,
-				TaxRate:          15,
+				LowerBoundIncome: 500001 * Baht,
+				TaxRate:          15 * Percent,
 			},
 		},
 		taxDeductions: []TaxDeduction{
-			{MaxDeductionAmount: 60000, TaxAllowanceType: "personal"},
-			{MaxDeductionAmount: 100000, TaxAllowanceType: "donation"},
+			{MaxDeductionAmount: 60000 * Baht, TaxAllowanceType: "personal"},
+			{MaxDeductionAmount: 100000 * Baht, TaxAllowanceType: "donation"},
 		},
 	})
 	err := h.CalculationCSV(c)
 
+	var res TaxCSVCalculation
+	json.Unmarshal(rec.Body.Bytes(), &res)
+
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []TaxWithTotalIncome{
+		{Row: 2, TotalIncome: 10000 * Baht, TaxRefund: 1000 * Baht},
+		{Row: 5, TotalIncome: 500000 * Baht, TaxAmount: 29000 * Baht},
+	}, res.Taxes)
+	assert.Equal(t, []CSVRowError{
+		{Row: 3, Message: "donation can not be string or empty"},
+		{Row: 4, Message: `bare " in non-quoted-field`},
+	}, res.Errors)
+	assert.Equal(t, CSVSummary{RowsOK: 2, RowsFailed: 2}, res.Summary)
+}
+
+func TestCalculationCSV_Columns(t *testing.T) {
 
+	e := echo.New()
+	e.Validator = helper.NewValidator()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.csv")
+	part.Write([]byte("employeeId,name,k-receipt,wht,totalIncome\nE0001,Somchai,50000,0,500000\nE0002,Somsri,0,1000,0"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	h := New(&MockTax{
+		taxRates: []TaxRate{
+			{ID: 1, LowerBoundIncome: 0.0, TaxRate: 0},
+			{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+		},
+		taxDeductions: []TaxDeduction{
+			{MaxDeductionAmount: 60000 * Baht, TaxAllowanceType: "personal"},
+			{MaxDeductionAmount: 50000 * Baht, TaxAllowanceType: "k-receipt"},
+		},
+	})
+	err := h.CalculationCSV(c)
+
+	var res TaxCSVCalculation
+	json.Unmarshal(rec.Body.Bytes(), &res)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []TaxWithTotalIncome{
+		{Row: 2, EmployeeID: "E0001", Name: "Somchai", TotalIncome: 500000 * Baht, TaxAmount: 24000 * Baht},
+	}, res.Taxes)
+	assert.Equal(t, []CSVRowError{
+		{Row: 3, EmployeeID: "E0002", Message: "Field TotalIncome is required_without Incomes"},
+	}, res.Errors)
+
+	t.Run("unknown column", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "test.csv")
+		part.Write([]byte("totalIncome,lottery\n500000,1000"))
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.CalculationCSV(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "unknown column lottery", jsonMashal(rec.Body.Bytes()).Message)
+	})
+
+	for _, test := range []struct {
+		name    string
+		content string
+		code    int
+	}{
+		{name: "rent with its asset type", content: "employeeId,rent,assetType\nE0003,1000000,building", code: http.StatusOK},
+		{name: "rent without an asset type", content: "employeeId,rent\nE0003,1000000", code: http.StatusBadRequest},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("file", "test.csv")
+			part.Write([]byte(test.content))
+			writer.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.CalculationCSV(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, rec.Code)
+			if test.code != http.StatusOK {
+				assert.Equal(t, "rent column needs an assetType column", jsonMashal(rec.Body.Bytes()).Message)
+				return
+			}
+
+			var res TaxCSVCalculation
+			json.Unmarshal(rec.Body.Bytes(), &res)
+			assert.Empty(t, res.Errors)
+			assert.Equal(t, []TaxWithTotalIncome{{Row: 2, EmployeeID: "E0003", TotalIncome: 1000000 * Baht, TaxAmount: 49000 * Baht}}, res.Taxes)
+		})
+	}
 }
 
-func TestCalculationCSV_Failure(t *testing.T) {
+func TestCalculationCSV_Download(t *testing.T) {
+
+	upload := func(accept string) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.Validator = helper.NewValidator()
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "test.csv")
+		part.Write([]byte("employeeId,totalIncome,donation\nE0001,500000,0\nE0002,600000,abc"))
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(&MockTax{
+			taxRates: []TaxRate{
+				{ID: 1, LowerBoundIncome: 0.0, TaxRate: 0},
+				{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+			},
+			taxDeductions: []TaxDeduction{
+				{MaxDeductionAmount: 60000 * Baht, TaxAllowanceType: "personal"},
+				{MaxDeductionAmount: 100000 * Baht, TaxAllowanceType: "donation"},
+			},
+		})
+		err := h.CalculationCSV(c)
+		assert.NoError(t, err)
+		return rec
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		rec := upload(helper.MIMETextCSV)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, helper.MIMETextCSV, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "\ufeff"+
+			"employeeId,totalIncome,donation,tax,taxRefund,netIncome,\"taxLevel 0-150,000\",\"taxLevel 150,001 ขึ้นไป\",error\n"+
+			"E0001,500000,0,29000.00,0.00,440000.00,0.00,29000.00,\n"+
+			"E0002,600000,abc,,,,,,donation can not be string or empty\n", rec.Body.String())
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		rec := upload(helper.MIMEXLSX)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, helper.MIMEXLSX, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "PK", rec.Body.String()[:2])
+	})
+}
+
+func TestCalculationCSV_RowErrors(t *testing.T) {
 
 	tests := []struct {
 		name        string
 		csvContent  string
 		expectedErr string
 	}{
-		{
-			name:        "Invalid CSV data",
-			csvContent:  "InvalidCSVData",
-			expectedErr: "Invalid Header",
-		},
 		{
 			name:        "Empty totalIncone",
 			csvContent:  "totalIncome,wht,donation\n ,1000,500",
@@ -527,6 +773,11 @@ func TestCalculationCSV_Failure(t *testing.T) {
 			csvContent:  "totalIncome,wht,donation\n10000,100000,0",
 			expectedErr: "invalid withholding tax amount",
 		},
+		{
+			name:        "Missing field",
+			csvContent:  "totalIncome,wht,donation\n10000,1000",
+			expectedErr: "wrong number of fields",
+		},
 	}
 
 	for _, test := range tests {
@@ -546,7 +797,73 @@ func TestCalculationCSV_Failure(t *testing.T) {
 			c := e.NewContext(req, rec)
 
 			h := New(&MockTax{
-				errorTaxRate: errors.New(test.expectedErr),
+				taxRates: []TaxRate{
+					{ID: 1, LowerBoundIncome: 0.0, TaxRate: 0},
+					{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+				},
+				taxDeductions: []TaxDeduction{
+					{MaxDeductionAmount: 60000 * Baht, TaxAllowanceType: "personal"},
+					{MaxDeductionAmount: 100000 * Baht, TaxAllowanceType: "donation"},
+				},
+			})
+
+			err := h.CalculationCSV(c)
+
+			var res TaxCSVCalculation
+			json.Unmarshal(rec.Body.Bytes(), &res)
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, []CSVRowError{{Row: 2, Message: test.expectedErr}}, res.Errors)
+			assert.Equal(t, CSVSummary{RowsFailed: 1}, res.Summary)
+		})
+	}
+}
+
+func TestCalculationCSV_Failure(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		csvContent  string
+		expectedErr string
+	}{
+		{
+			name:        "Invalid CSV data",
+			csvContent:  "InvalidCSVData",
+			expectedErr: "Invalid Header",
+		},
+		{
+			name:        "Empty CSV Data",
+			csvContent:  "",
+			expectedErr: "Invalid Header",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = helper.NewValidator()
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("file", "test.csv")
+			part.Write([]byte(test.csvContent))
+			writer.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			h := New(&MockTax{
+				taxRates: []TaxRate{
+					{ID: 1, LowerBoundIncome: 0.0, TaxRate: 0},
+					{ID: 2, LowerBoundIncome: 150001 * Baht, TaxRate: 10 * Percent},
+				},
+				taxDeductions: []TaxDeduction{
+					{MaxDeductionAmount: 60000 * Baht, TaxAllowanceType: "personal"},
+					{MaxDeductionAmount: 100000 * Baht, TaxAllowanceType: "donation"},
+				},
 			})
 
 			err := h.CalculationCSV(c)
@@ -577,15 +894,6 @@ func TestCalculationCSV_Failure(t *testing.T) {
 		assert.Equal(t, "http: no such file", jsonMashal(rec.Body.Bytes()).Message)
 	})
 
-	t.Run("Empty CSV Data", func(t *testing.T) {
-		reader := strings.NewReader("")
-		recs, err := readCSVRecords(reader)
-
-		assert.NoError(t, err)
-		assert.Nil(t, recs)
-
-	})
-
 	t.Run("Failed Get Tax Rate", func(t *testing.T) {
 		e := echo.New()
 		e.Validator = helper.NewValidator()
@@ -603,6 +911,10 @@ func TestCalculationCSV_Failure(t *testing.T) {
 
 		h := New(&MockTax{
 			errorTaxRate: errors.New("error get tax rates"),
+			taxDeductions: []TaxDeduction{
+				{MaxDeductionAmount: 60000 * Baht, TaxAllowanceType: "personal"},
+				{MaxDeductionAmount: 100000 * Baht, TaxAllowanceType: "donation"},
+			},
 		})
 
 		err := h.CalculationCSV(c)
@@ -641,31 +953,23 @@ func TestCalculationCSV_Failure(t *testing.T) {
 func TestRemoveBOM(t *testing.T) {
 	tests := []struct {
 		name           string
-		input          [][]string
-		expectedOutput [][]string
+		input          []string
+		expectedOutput []string
 	}{
 		{
-			name: "Input with BOM",
-			input: [][]string{
-				{"\ufefftotalIncome", "wht", "donation"},
-			},
-			expectedOutput: [][]string{
-				{"totalIncome", "wht", "donation"},
-			},
+			name:           "Input with BOM",
+			input:          []string{"\ufefftotalIncome", "wht", "donation"},
+			expectedOutput: []string{"totalIncome", "wht", "donation"},
 		},
 		{
-			name: "Input without BOM",
-			input: [][]string{
-				{"totalIncome", "wht", "donation"},
-			},
-			expectedOutput: [][]string{
-				{"totalIncome", "wht", "donation"},
-			},
+			name:           "Input without BOM",
+			input:          []string{"totalIncome", "wht", "donation"},
+			expectedOutput: []string{"totalIncome", "wht", "donation"},
 		},
 		{
 			name:           "Empty input",
-			input:          [][]string{},
-			expectedOutput: [][]string{},
+			input:          []string{},
+			expectedOutput: []string{},
 		},
 	}
 
@@ -681,23 +985,23 @@ func TestRefundTax(t *testing.T) {
 
 	tests := []struct {
 		name           string
-		input          float64
-		expectedOutput []float64
+		input          Money
+		expectedOutput []Money
 	}{
 		{
 			name:           "Positive input",
-			input:          10000,
-			expectedOutput: []float64{10000, 0},
+			input:          10000 * Baht,
+			expectedOutput: []Money{10000 * Baht, 0},
 		},
 		{
 			name:           "Zero input",
 			input:          0,
-			expectedOutput: []float64{0, 0},
+			expectedOutput: []Money{0, 0},
 		},
 		{
 			name:           "Negative input",
-			input:          -10000,
-			expectedOutput: []float64{0, 10000},
+			input:          -10000 * Baht,
+			expectedOutput: []Money{0, 10000 * Baht},
 		},
 	}
 
@@ -712,49 +1016,153 @@ func TestRefundTax(t *testing.T) {
 
 }
 
-func TestEqualSlice(t *testing.T) {
-	tests := []struct {
-		name         string
-		sliceA       []string
-		sliceB       []string
-		expectedBool bool
-	}{
-		{
-			name:         "Equal slices",
-			sliceA:       []string{"a", "b", "c"},
-			sliceB:       []string{"a", "b", "c"},
-			expectedBool: true,
-		},
-		{
-			name:         "Unequal slices",
-			sliceA:       []string{"a", "b", "c"},
-			sliceB:       []string{"a", "b", "d"},
-			expectedBool: false,
-		},
-		{
-			name:         "Different lengths",
-			sliceA:       []string{"a", "b", "c"},
-			sliceB:       []string{"a", "b", "c", "d"},
-			expectedBool: false,
-		},
-		{
-			name:         "Empty slices",
-			sliceA:       []string{},
-			sliceB:       []string{},
-			expectedBool: true,
+func jsonMashal(b []byte) helper.ErrorMessage {
+	var eMsg helper.ErrorMessage
+	json.Unmarshal(b, &eMsg)
+	return eMsg
+}
+
+// countingStore counts the store reads and waits on each of them as a round
+// trip to the database would.
+type countingStore struct {
+	MockTax
+	latency time.Duration
+	queries atomic.Int64
+}
+
+func (s *countingStore) TaxDeductionByType(taxYear int, allowanceTypes []string) ([]TaxDeduction, error) {
+	s.queries.Add(1)
+	time.Sleep(s.latency)
+	return s.MockTax.TaxDeductionByType(taxYear, allowanceTypes)
+}
+
+func (s *countingStore) TaxRates(taxYear int) ([]TaxRate, error) {
+	s.queries.Add(1)
+	time.Sleep(s.latency)
+	return s.MockTax.TaxRates(taxYear)
+}
+
+func (s *countingStore) TaxSnapshot(taxYear int, allowanceTypes []string) (Snapshot, error) {
+	s.queries.Add(2)
+	time.Sleep(s.latency)
+	return s.MockTax.TaxSnapshot(taxYear, allowanceTypes)
+}
+
+func benchmarkStore() *countingStore {
+	return &countingStore{
+		latency: 50 * time.Microsecond,
+		MockTax: MockTax{
+			taxRates:      thaiTaxRates(),
+			taxDeductions: []TaxDeduction{{MaxDeductionAmount: 60000 * Baht, TaxAllowanceType: "personal"}, {MaxDeductionAmount: 100000 * Baht, TaxAllowanceType: "donation"}},
 		},
 	}
+}
 
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			result := equalSlice(test.sliceA, test.sliceB)
-			assert.Equal(t, test.expectedBool, result)
+func benchmarkUpload(rows int) []byte {
+	var b strings.Builder
+	b.WriteString("employeeId,totalIncome,wht,donation\n")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "E%05d,%d,%d,%d\n", i, 300000+i*100, i%5000, i%20000)
+	}
+	return []byte(b.String())
+}
+
+// BenchmarkCalculationCSV uploads 2,000 rows. per-row-rates is the handler as
+// it was before snapshots, reading the rates for every row and working the
+// rows out one after the other, for comparison.
+func BenchmarkCalculationCSV(b *testing.B) {
+	const rows = 2000
+	upload := benchmarkUpload(rows)
+
+	run := func(b *testing.B, store *countingStore, handler echo.HandlerFunc) {
+		e := echo.New()
+		e.Validator = helper.NewValidator()
+
+		for i := 0; i < b.N; i++ {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("file", "bench.csv")
+			part.Write(upload)
+			writer.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			rec := httptest.NewRecorder()
+
+			if err := handler(e.NewContext(req, rec)); err != nil || rec.Code != http.StatusOK {
+				b.Fatalf("upload failed: %v %d", err, rec.Code)
+			}
+		}
+
+		b.ReportMetric(float64(store.queries.Load())/float64(b.N), "queries/op")
+		b.ReportMetric(float64(rows*b.N)/b.Elapsed().Seconds(), "rows/s")
+	}
+
+	for _, workers := range slices.Compact([]int{1, runtime.NumCPU()}) {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			store := benchmarkStore()
+			h := New(store)
+			h.workers = workers
+			run(b, store, h.CalculationCSV)
 		})
 	}
+
+	b.Run("per-row-rates", func(b *testing.B) {
+		store := benchmarkStore()
+		run(b, store, perRowRatesCSV(store))
+	})
 }
 
-func jsonMashal(b []byte) helper.ErrorMessage {
-	var eMsg helper.ErrorMessage
-	json.Unmarshal(b, &eMsg)
-	return eMsg
+// perRowRatesCSV works out an upload the way CalculationCSV did before it read
+// one snapshot per upload.
+func perRowRatesCSV(store Storer) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		fileUploaded, err := openFile(c)
+		if err != nil {
+			return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+		}
+		defer fileUploaded.Close()
+
+		read := csv.NewReader(fileUploaded)
+		_, schema, err := readCSVHeader(read)
+		if err != nil {
+			return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+		}
+
+		types := append(schema.allowanceTypes(), "personal")
+		tds, err := store.TaxDeductionByType(CurrentTaxYear(), types)
+		if err != nil {
+			return helper.FailedHandler(c, err.Error())
+		}
+		if err := schema.known(tds); err != nil {
+			return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+		}
+
+		var res TaxCSVCalculation
+		for {
+			v, err := read.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return helper.FailedHandler(c, err.Error(), http.StatusBadRequest)
+			}
+
+			r := csvRow{cells: v}
+			r.line, _ = read.FieldPos(0)
+			r.tc, r.tti, r.err = schema.calculation(v)
+			if r.err == nil {
+				r.err = c.Validate(&r.tc)
+			}
+			if r.err == nil {
+				rates, err := store.TaxRates(CurrentTaxYear())
+				if err != nil {
+					return helper.FailedHandler(c, err.Error())
+				}
+				r.res, r.err = Calculate(r.tc, rates, tds)
+			}
+			res.add(r, nil)
+		}
+		return helper.SuccessHandler(c, res)
+	}
 }
@@ -0,0 +1,36 @@
+package tax
+
+import "errors"
+
+// Withhold works out this month's PND 1 withholding the way the Revenue
+// Department lays it out: estimate the year's salary from what has been paid
+// so far plus this month's salary for every month left, work out the tax on
+// it, and spread what has not been withheld yet over the months left, in whole
+// baht.
+func Withhold(wc WithholdingCalculation, rates []TaxRate, tds []TaxDeduction) (WithholdingResponse, error) {
+	if wc.MonthsPaid >= wc.MonthsEmployed {
+		return WithholdingResponse{}, errors.New("months paid must be less than months employed")
+	}
+	if wc.YTDIncome < 0 || wc.YTDWithholding < 0 {
+		return WithholdingResponse{}, errors.New("year to date amounts can not be negative")
+	}
+
+	remaining := wc.MonthsEmployed - wc.MonthsPaid
+	annualIncome := wc.YTDIncome + wc.MonthlySalary*Money(remaining)
+
+	res, err := Calculate(TaxCalculation{
+		Incomes:    []Income{{IncomeType: IncomeSalary, Amount: annualIncome}},
+		Allowances: wc.Allowances,
+		TaxYear:    wc.TaxYear,
+	}, rates, tds)
+	if err != nil {
+		return WithholdingResponse{}, err
+	}
+
+	return WithholdingResponse{
+		AnnualIncome:    annualIncome,
+		AnnualTax:       res.TaxBeforeWht,
+		RemainingMonths: remaining,
+		Withholding:     max(res.TaxBeforeWht-wc.YTDWithholding, 0).MulDiv(1, int64(remaining)).WholeBaht(),
+	}, nil
+}
@@ -0,0 +1,54 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithhold(t *testing.T) {
+	personal := TaxDeduction{TaxAllowanceType: "personal", MaxDeductionAmount: 60000 * Baht}
+
+	tests := []struct {
+		name     string
+		wc       WithholdingCalculation
+		expected WithholdingResponse
+	}{
+		{
+			name:     "first month of a full year",
+			wc:       WithholdingCalculation{MonthlySalary: 50000 * Baht, MonthsEmployed: 12},
+			expected: WithholdingResponse{AnnualIncome: 600000 * Baht, AnnualTax: 29000 * Baht, RemainingMonths: 12, Withholding: 2416 * Baht},
+		},
+		{
+			name:     "after a raise",
+			wc:       WithholdingCalculation{MonthlySalary: 60000 * Baht, MonthsEmployed: 12, MonthsPaid: 6, YTDIncome: 300000 * Baht, YTDWithholding: 14500 * Baht},
+			expected: WithholdingResponse{AnnualIncome: 660000 * Baht, AnnualTax: 35000 * Baht, RemainingMonths: 6, Withholding: 3416 * Baht},
+		},
+		{
+			name:     "withheld more than the year's tax",
+			wc:       WithholdingCalculation{MonthlySalary: 20000 * Baht, MonthsEmployed: 12, MonthsPaid: 6, YTDIncome: 300000 * Baht, YTDWithholding: 20000 * Baht},
+			expected: WithholdingResponse{AnnualIncome: 420000 * Baht, AnnualTax: 11000 * Baht, RemainingMonths: 6, Withholding: 0},
+		},
+		{
+			name:     "joined mid year",
+			wc:       WithholdingCalculation{MonthlySalary: 50000 * Baht, MonthsEmployed: 6},
+			expected: WithholdingResponse{AnnualIncome: 300000 * Baht, AnnualTax: 0, RemainingMonths: 6, Withholding: 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := Withhold(test.wc, thaiTaxRates(), []TaxDeduction{personal})
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, res)
+		})
+	}
+
+	t.Run("every month already paid", func(t *testing.T) {
+		_, err := Withhold(WithholdingCalculation{MonthlySalary: 50000 * Baht, MonthsEmployed: 6, MonthsPaid: 6}, thaiTaxRates(), nil)
+
+		assert.Equal(t, errors.New("months paid must be less than months employed"), err)
+	})
+}